@@ -4,6 +4,7 @@ import (
 	"CatsSocial/api/responses"
 	"CatsSocial/db/functions"
 	"CatsSocial/db/models"
+	"CatsSocial/storage"
 	"errors"
 	"fmt"
 	"net/http"
@@ -16,25 +17,12 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-type Race string
-
-const (
-	Persian           Race = "Persian"
-	Maine_Coon        Race = "Maine Coon"
-	Siamese           Race = "Siamese"
-	Ragdoll           Race = "Ragdoll"
-	Bengal            Race = "Bengal"
-	Sphynx            Race = "Sphynx"
-	British_Shorthair Race = "British Shorthair"
-	Abyssinian        Race = "Abyssinian"
-	Scottish_Fold     Race = "Scottish Fold"
-	Birman            Race = "Birman"
-)
-
 type (
 	Cat struct {
 		Database     *functions.Cat
 		UserDatabase *functions.User
+		Races        *functions.Race
+		Storage      storage.Storage
 	}
 
 	CatPayload struct {
@@ -52,10 +40,12 @@ type (
 		Offset     int    `json:"offset"`
 		Race       string `json:"Race"`
 		Sex        string `json:"sex"`
-		HasMatched bool   `json:"hasMatched"`
+		HasMatched string `json:"hasMatched"`
 		AgeInMonth string `json:"ageInMonth"`
 		Owned      bool   `json:"owned"`
 		Search     string `json:"search"`
+		SearchMode string `json:"searchMode"`
+		Cursor     string `json:"cursor"`
 	}
 
 	CatResponse struct {
@@ -73,27 +63,41 @@ type (
 		ImageUrls   []string `json:"imageUrls"`
 		HasMatched  bool     `json:"hasMatched"`
 		CreatedAt   string   `json:"createdAt"`
+		// DeletedAt is only set for deleted cats shown in match history.
+		DeletedAt string `json:"deletedAt,omitempty"`
+		// Highlight holds the relevance and the highlighted snippets of a full text search.
+		Highlight *models.CatHighlight `json:"highlight,omitempty"`
 	}
 
 	Meta struct {
-		Limit  int `json:"limit"`
-		Offset int `json:"offset"`
-		Total  int `json:"total"`
+		Limit      int    `json:"limit"`
+		Offset     int    `json:"offset"`
+		Total      int    `json:"total"`
+		NextCursor string `json:"nextCursor,omitempty"`
 	}
 
 	GetCatsResponse struct {
 		Data []CatDetailResponse `json:"data"`
-		//Meta Meta                `json:"meta"`
+		Meta Meta                `json:"meta"`
 	}
 )
 
-func (app CatPayload) Validate() error {
+// toInterfaces adapts a string slice to the variadic argument of validation.In.
+func toInterfaces(values []string) []interface{} {
+	result := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		result = append(result, v)
+	}
+	return result
+}
+
+func (app CatPayload) Validate(races []string) error {
 
 	return validation.ValidateStruct(&app,
 		// Name cannot be empty, and the length must be between 1 and 30.
 		validation.Field(&app.Name, validation.Required, validation.Length(1, 30)),
-		// Race cannot be empty, and should be in the Race enum.
-		validation.Field(&app.Race, validation.Required, validation.In("Persian", "Maine Coon", "Siamese", "Ragdoll", "Bengal", "Sphynx", "British Shorthair", "Abyssinian", "Scottish Fold", "Birman")),
+		// Race cannot be empty, and should be in the race catalogue.
+		validation.Field(&app.Race, validation.Required, validation.In(toInterfaces(races)...)),
 		// Sex cannot be empty and should be either "male" or "female".
 		validation.Field(&app.Sex, validation.Required, validation.In("male", "female")),
 		// Stock cannot be empty, and minimum value is 1 and maximum value is 120082
@@ -105,30 +109,31 @@ func (app CatPayload) Validate() error {
 	)
 }
 
-func (app QueryFilterGetCats) Validate() error {
+func (app QueryFilterGetCats) Validate(races []string) error {
 	return validation.ValidateStruct(&app,
 		// Limit should be greater than 0.
 		validation.Field(&app.Limit, validation.Min(0)),
 		// Offset cannot should be greater than 0.
 		validation.Field(&app.Offset, validation.Min(0)),
-		// Race should be in the Race enum.
-		validation.Field(&app.Race, validation.In("Persian", "Maine Coon", "Siamese", "Ragdoll", "Bengal", "Sphynx", "British Shorthair", "Abyssinian", "Scottish Fold", "Birman")),
+		// Race should be in the race catalogue.
+		validation.Field(&app.Race, validation.In(toInterfaces(races)...)),
 		// Sex should be either "male" or "female".
 		validation.Field(&app.Sex, validation.In("male", "female")),
+		// HasMatched should be either "true" or "false".
+		validation.Field(&app.HasMatched, validation.In("true", "false")),
+		// SearchMode should be either "name" or "fulltext".
+		validation.Field(&app.SearchMode, validation.In("name", models.SearchModeFullText)),
 	)
 }
 
 func parseAgeInMonthQuery(query string) (string, int, error) {
 
 	operator := "="
-	val := query[1:]
+	val := query
 
-	if strings.HasPrefix(query, "<") {
-		operator = "<"
-	} else if strings.HasPrefix(query, ">") {
-		operator = ">"
-	} else {
-		operator = "="
+	if strings.HasPrefix(query, "<") || strings.HasPrefix(query, ">") || strings.HasPrefix(query, "=") {
+		operator = query[:1]
+		val = query[1:]
 	}
 
 	value, err := strconv.Atoi(val)
@@ -157,28 +162,36 @@ func (p *Cat) convertCatModelToDetailResponse(cat models.Cat) CatDetailResponse
 		ImageUrls:   cat.ImageUrls,
 		HasMatched:  cat.HasMatched,
 		CreatedAt:   cat.CreatedAt.Format(time.RFC3339),
+		Highlight:   cat.Highlight,
 	}
 }
 
 func (p *Cat) convertCatsToGetCatsResponse(
 	cats []models.Cat,
 	limit, offset, total int,
-) []CatDetailResponse {
-	var result []CatDetailResponse
+) GetCatsResponse {
+	result := []CatDetailResponse{}
 	for _, cat := range cats {
 		result = append(result, p.convertCatModelToDetailResponse(cat))
 	}
 
-	// return GetCatsResponse{
-	// 	Data: result,
-	// 	// Meta: Meta{
-	// 	// 	Limit:  limit,
-	// 	// 	Offset: offset,
-	// 	// 	Total:  total,
-	// 	// },
-	// }
+	meta := Meta{
+		Limit:  limit,
+		Offset: offset,
+		Total:  total,
+	}
 
-	return result
+	// a full page means there may be more cats after the last one, pages ranked
+	// by relevance are not ordered by created_at so they cannot hand out a cursor
+	if len(cats) > 0 && len(cats) == limit && cats[len(cats)-1].Highlight == nil {
+		last := cats[len(cats)-1]
+		meta.NextCursor = encodeCursor(last.CreatedAt, last.Id)
+	}
+
+	return GetCatsResponse{
+		Data: result,
+		Meta: meta,
+	}
 }
 
 func (p *Cat) handleError(c *fiber.Ctx, err error) error {
@@ -187,7 +200,7 @@ func (p *Cat) handleError(c *fiber.Ctx, err error) error {
 		return fiber.ErrUnauthorized
 	case errors.Is(err, fiber.ErrForbidden):
 		return fiber.ErrForbidden
-	case errors.Is(err, functions.ErrNoRow):
+	case errors.Is(err, fiber.ErrNotFound), errors.Is(err, functions.ErrNoRow):
 		status, response := responses.ErrorNotFound("no cat found")
 		return c.Status(status).JSON(response)
 	default:
@@ -223,7 +236,12 @@ func (p *Cat) GetCats(c *fiber.Ctx) error {
 		return p.handleError(c, errors.New(fmt.Sprintf("failed to parse query params: %v", err.Error())))
 	}
 
-	err = filter.Validate()
+	races, err := p.Races.Names(c.UserContext())
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	err = filter.Validate(races)
 	if err != nil {
 		return p.handleError(c, err)
 	}
@@ -243,17 +261,39 @@ func (p *Cat) GetCats(c *fiber.Ctx) error {
 		}
 	}
 
+	if filter.Limit == 0 {
+		filter.Limit = 5
+	}
+
+	var cursor *models.Cursor
+	if filter.Cursor != "" {
+		cursor, err = decodeCursor(filter.Cursor)
+		if err != nil {
+			return p.handleError(c, validation.Errors{"cursor": errors.New("is not a valid cursor")})
+		}
+		// offset is meaningless when paging with a cursor
+		filter.Offset = 0
+	}
+
+	var hasMatched *bool
+	if filter.HasMatched != "" {
+		matched := filter.HasMatched == "true"
+		hasMatched = &matched
+	}
+
 	filterDB := models.FilterGetCats{
 		Id:                 filter.Id,
 		Limit:              filter.Limit,
 		Offset:             filter.Offset,
 		Race:               filter.Race,
 		Sex:                filter.Sex,
-		HasMatched:         filter.HasMatched,
+		HasMatched:         hasMatched,
 		AgeInMonthOperator: operator,
 		AgeInMonthValue:    value,
 		Owned:              filter.Owned,
 		Search:             filter.Search,
+		SearchMode:         filter.SearchMode,
+		Cursor:             cursor,
 	}
 
 	cats, err := p.Database.FindAll(c.UserContext(), filterDB, userID)
@@ -266,21 +306,12 @@ func (p *Cat) GetCats(c *fiber.Ctx) error {
 		return p.handleError(c, err)
 	}
 
-	// if filter.Limit == 0 {
-	// 	filter.Limit = 5
-	// }
-
 	result := p.convertCatsToGetCatsResponse(cats, filter.Limit, filter.Offset, total)
 
-	if len(result) == 0 {
-		return c.Status(http.StatusOK).JSON(map[string]interface{}{
-			"message": "success",
-			"data":    []interface{}{},
-		})
-	}
 	return c.Status(http.StatusOK).JSON(map[string]interface{}{
 		"message": "success",
-		"data":    result,
+		"data":    result.Data,
+		"meta":    result.Meta,
 	})
 }
 
@@ -301,7 +332,12 @@ func (p *Cat) AddCat(c *fiber.Ctx) error {
 		return c.SendStatus(http.StatusBadRequest)
 	}
 
-	err = payload.Validate()
+	races, err := p.Races.Names(c.UserContext())
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	err = payload.Validate(races)
 	if err != nil {
 		return p.handleError(c, err)
 	}
@@ -345,7 +381,12 @@ func (p *Cat) UpdateCat(c *fiber.Ctx) error {
 		return c.SendStatus(http.StatusBadRequest)
 	}
 
-	err = payload.Validate()
+	races, err := p.Races.Names(c.UserContext())
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	err = payload.Validate(races)
 	if err != nil {
 		return p.handleError(c, err)
 	}
@@ -368,6 +409,8 @@ func (p *Cat) UpdateCat(c *fiber.Ctx) error {
 		return p.handleError(c, fiber.ErrBadRequest)
 	}
 
+	previousImageUrls := cat.ImageUrls
+
 	cat.Name = payload.Name
 	cat.Race = payload.Race
 	cat.Sex = payload.Sex
@@ -380,6 +423,8 @@ func (p *Cat) UpdateCat(c *fiber.Ctx) error {
 		return p.handleError(c, err)
 	}
 
+	storage.RemoveUnused(c.UserContext(), p.Storage, p.Database.IsImageUsed, previousImageUrls)
+
 	return c.Status(http.StatusOK).JSON(map[string]interface{}{})
 }
 
@@ -408,7 +453,89 @@ func (p *Cat) DeleteCat(c *fiber.Ctx) error {
 		return p.handleError(c, err)
 	}
 
-	err = p.Database.DeleteByID(c.UserContext(), catID)
+	err = p.Database.DeleteByID(c.UserContext(), catID, userID)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{})
+}
+
+func (p *Cat) GetRaces(c *fiber.Ctx) error {
+	races, err := p.Races.FindAll(c.UserContext())
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "success",
+		"data":    races,
+	})
+}
+
+// GetRecommendations suggests partners for one of the user's cats, best first.
+func (p *Cat) GetRecommendations(c *fiber.Ctx) error {
+	userIDClaim := c.Locals("user_id").(string)
+	userID, err := strconv.Atoi(userIDClaim)
+	if err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed parse user id: %v", err.Error())))
+	}
+
+	catID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return p.handleError(c, fiber.ErrNotFound)
+	}
+
+	var filter QueryFilterGetCats
+	if err := c.QueryParser(&filter); err != nil {
+		return p.handleError(c, errors.New(fmt.Sprintf("failed to parse query params: %v", err.Error())))
+	}
+
+	err = validation.ValidateStruct(&filter,
+		validation.Field(&filter.Limit, validation.Min(0)),
+		validation.Field(&filter.Offset, validation.Min(0)),
+	)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = 5
+	}
+
+	cat, err := p.Database.FindByIDUser(c.UserContext(), catID, userID)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	if cat.HasMatched {
+		status, response := responses.ErrorBadRequests(functions.ErrAlreadyMatched.Error())
+		return c.Status(status).JSON(response)
+	}
+
+	cats, err := p.Database.FindRecommendations(c.UserContext(), cat, filter.Limit, filter.Offset)
+	if err != nil {
+		return p.handleError(c, err)
+	}
+
+	result := []CatDetailResponse{}
+	for _, cat := range cats {
+		result = append(result, p.convertCatModelToDetailResponse(cat))
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "success",
+		"data":    result,
+	})
+}
+
+func (p *Cat) RestoreCat(c *fiber.Ctx) error {
+	catID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return p.handleError(c, errors.New("failed parse cat id"))
+	}
+
+	err = p.Database.Restore(c.UserContext(), catID)
 	if err != nil {
 		return p.handleError(c, err)
 	}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"CatsSocial/db/models"
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// encodeCursor builds an opaque cursor pointing right after the row with the
+// given created_at and id.
+func encodeCursor(createdAt time.Time, id int) string {
+	raw := createdAt.Format(time.RFC3339Nano) + "|" + strconv.Itoa(id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (*models.Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	createdAt, id, found := strings.Cut(string(raw), "|")
+	if !found {
+		return nil, errors.New("malformed cursor")
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, createdAt)
+	if err != nil {
+		return nil, err
+	}
+
+	rowID, err := strconv.Atoi(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Cursor{CreatedAt: t, Id: rowID}, nil
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name      string
+		createdAt time.Time
+		id        int
+	}{
+		{"utc", time.Date(2024, 5, 1, 10, 30, 0, 0, time.UTC), 1},
+		{"nanoseconds", time.Date(2024, 5, 1, 10, 30, 0, 123456789, time.UTC), 42},
+		{"offset", time.Date(2024, 5, 1, 10, 30, 0, 0, time.FixedZone("WIB", 7*60*60)), 1000000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor, err := decodeCursor(encodeCursor(tt.createdAt, tt.id))
+			if err != nil {
+				t.Fatalf("decodeCursor() error = %v", err)
+			}
+
+			if !cursor.CreatedAt.Equal(tt.createdAt) || cursor.Id != tt.id {
+				t.Errorf("decodeCursor() = %v/%d, want %v/%d", cursor.CreatedAt, cursor.Id, tt.createdAt, tt.id)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+
+	tests := []struct {
+		name   string
+		cursor string
+	}{
+		{"not base64", "not a cursor!"},
+		{"no separator", encode("2024-05-01T10:30:00Z")},
+		{"bad time", encode("yesterday|1")},
+		{"bad id", encode("2024-05-01T10:30:00Z|one")},
+		{"empty", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeCursor(tt.cursor); err == nil {
+				t.Errorf("decodeCursor(%q) error = nil, want an error", tt.cursor)
+			}
+		})
+	}
+}
@@ -1,12 +1,20 @@
 package handlers
 
 import (
+	"CatsSocial/auth"
 	"CatsSocial/configs"
+	"CatsSocial/mailer"
+	"CatsSocial/storage"
+	"CatsSocial/stream"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Dependencies struct {
-	Cfg    configs.Config
-	DbPool *pgxpool.Pool
+	Cfg     configs.Config
+	DbPool  *pgxpool.Pool
+	Auth    *auth.Auth
+	Storage storage.Storage
+	Hub     *stream.Hub
+	Mailer  mailer.Mailer
 }
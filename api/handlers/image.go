@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"CatsSocial/api/responses"
+	"CatsSocial/storage"
+	"io"
+	"net/http"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+)
+
+// allowedImageTypes maps the sniffed content type of an upload to its file extension.
+var allowedImageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+type Image struct {
+	Storage storage.Storage
+	MaxSize int64
+}
+
+func (i *Image) Upload(c *fiber.Ctx) error {
+	file, err := c.FormFile("file")
+	if err != nil {
+		status, response := responses.ErrorBadRequests("file is required")
+		return c.Status(status).JSON(response)
+	}
+
+	if file.Size == 0 || file.Size > i.MaxSize {
+		status, response := responses.ErrorBadRequests("file size is not allowed")
+		return c.Status(status).JSON(response)
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		status, response := responses.ErrorServer(err.Error())
+		return c.Status(status).JSON(response)
+	}
+	defer f.Close()
+
+	content, err := io.ReadAll(io.LimitReader(f, i.MaxSize+1))
+	if err != nil {
+		status, response := responses.ErrorServer(err.Error())
+		return c.Status(status).JSON(response)
+	}
+
+	if int64(len(content)) > i.MaxSize {
+		status, response := responses.ErrorBadRequests("file size is not allowed")
+		return c.Status(status).JSON(response)
+	}
+
+	// trust the bytes, not the Content-Type header sent by the client
+	contentType := http.DetectContentType(content)
+	ext, ok := allowedImageTypes[contentType]
+	if !ok {
+		status, response := responses.ErrorBadRequests("file type is not allowed")
+		return c.Status(status).JSON(response)
+	}
+
+	url, err := i.Storage.Save(c.UserContext(), "cats/"+uuid.NewString()+ext, contentType, content)
+	if err != nil {
+		status, response := responses.ErrorServer(err.Error())
+		return c.Status(status).JSON(response)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "File uploaded successfully",
+		"data": map[string]interface{}{
+			"imageUrl": url,
+		},
+	})
+}
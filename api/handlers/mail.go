@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// mailTimeout bounds the background work of one email, mailConcurrency how many
+// of them can be in flight at once. Match, verification and reset emails share
+// the same slots so a burst of requests cannot start unbounded goroutines.
+const (
+	mailTimeout     = time.Minute
+	mailConcurrency = 16
+)
+
+var (
+	mailSlots = make(chan struct{}, mailConcurrency)
+
+	errMailBusy = errors.New("too many emails are being sent, try again later")
+)
+
+// goMail runs send in the background with a timeout, or returns errMailBusy
+// without running it when mailConcurrency emails are already in flight.
+func goMail(send func(ctx context.Context)) error {
+	select {
+	case mailSlots <- struct{}{}:
+	default:
+		return errMailBusy
+	}
+
+	go func() {
+		defer func() { <-mailSlots }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+		defer cancel()
+
+		send(ctx)
+	}()
+
+	return nil
+}
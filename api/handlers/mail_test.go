@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGoMailIsBounded(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, mailConcurrency)
+
+	for i := 0; i < mailConcurrency; i++ {
+		err := goMail(func(ctx context.Context) {
+			started <- struct{}{}
+			<-release
+		})
+		if err != nil {
+			t.Fatalf("goMail() #%d = %v, want nil", i, err)
+		}
+	}
+
+	if err := goMail(func(ctx context.Context) {}); !errors.Is(err, errMailBusy) {
+		t.Errorf("goMail() past the limit = %v, want errMailBusy", err)
+	}
+
+	for i := 0; i < mailConcurrency; i++ {
+		<-started
+	}
+	close(release)
+
+	// the slots free up once the emails are sent
+	deadline := time.Now().Add(time.Second)
+	for len(mailSlots) > 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	done := make(chan struct{})
+	if err := goMail(func(ctx context.Context) { close(done) }); err != nil {
+		t.Fatalf("goMail() after the emails were sent = %v, want nil", err)
+	}
+	<-done
+}
@@ -4,6 +4,9 @@ import (
 	"CatsSocial/api/responses"
 	"CatsSocial/db/functions"
 	"CatsSocial/db/models"
+	"CatsSocial/mailer"
+	"CatsSocial/match"
+	"context"
 	"errors"
 	"fmt"
 	"net/http"
@@ -12,14 +15,27 @@ import (
 	"time"
 
 	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/go-ozzo/ozzo-validation/is"
 	"github.com/gofiber/fiber/v2"
 )
 
+const (
+	matchViewLive     = "live"
+	matchViewSnapshot = "snapshot"
+)
+
 type (
 	MatchHandler struct {
 		Match        functions.Match
 		CatDatabase  *functions.Cat
 		UserDatabase *functions.User
+		Rules        *match.Engine
+		// ApprovalRules are checked again when the receiver approves a request.
+		ApprovalRules *match.Engine
+		// Mailer emails match requests and approvals, nil turns emails off.
+		Mailer mailer.Mailer
+		// RequireVerifiedEmail stops users with an unverified email from sending requests.
+		RequireVerifiedEmail bool
 	}
 
 	MatchIssuer struct {
@@ -34,23 +50,100 @@ type (
 		MatchCatDetail CatDetailResponse `json:"matchCatDetail"`
 		UserCatDetail  CatDetailResponse `json:"userCatDetail"`
 		Message        string            `json:"message"`
+		Status         string            `json:"status"`
 		CreatedAt      string            `json:"createdAt"`
+		ExpiresAt      string            `json:"expiresAt,omitempty"`
+	}
+
+	QueryFilterGetMatches struct {
+		Direction   string `json:"direction"`
+		Status      string `json:"status"`
+		CatId       string `json:"catId"`
+		CreatedFrom string `json:"createdFrom"`
+		CreatedTo   string `json:"createdTo"`
+		Limit       int    `json:"limit"`
+		Offset      int    `json:"offset"`
+		Cursor      string `json:"cursor"`
 	}
 
 	GetMatchesResponse struct {
 		Data []MatchDetailResponse `json:"data"`
+		Meta Meta                  `json:"meta"`
 	}
 )
 
+var matchStatuses = []interface{}{
+	functions.MatchStatusPending,
+	functions.MatchStatusApproved,
+	functions.MatchStatusRejected,
+	functions.MatchStatusWithdrawn,
+	functions.MatchStatusSuperseded,
+	functions.MatchStatusExpired,
+}
+
+// statuses splits the comma separated status query param.
+func (app QueryFilterGetMatches) statuses() []string {
+	if app.Status == "" {
+		return nil
+	}
+
+	return strings.Split(app.Status, ",")
+}
+
+func (app QueryFilterGetMatches) Validate() error {
+	err := validation.ValidateStruct(&app,
+		// Direction should be either "incoming" or "outgoing".
+		validation.Field(&app.Direction, validation.In(models.MatchDirectionIncoming, models.MatchDirectionOutgoing)),
+		// CatId should be a cat id.
+		validation.Field(&app.CatId, is.Int),
+		// CreatedFrom and CreatedTo should be ISO 8601 dates.
+		validation.Field(&app.CreatedFrom, validation.Date(time.RFC3339)),
+		validation.Field(&app.CreatedTo, validation.Date(time.RFC3339)),
+		// Limit should be greater than 0.
+		validation.Field(&app.Limit, validation.Min(0)),
+		// Offset should be greater than 0.
+		validation.Field(&app.Offset, validation.Min(0)),
+	)
+	if err != nil {
+		return err
+	}
+
+	// Status should be a comma separated list of match statuses.
+	if err := validation.Validate(app.statuses(), validation.Each(validation.In(matchStatuses...))); err != nil {
+		return validation.Errors{"status": err}
+	}
+
+	return nil
+}
+
 func (m *MatchHandler) handleError(c *fiber.Ctx, err error) error {
+	var violation *match.Violation
+
 	switch {
 	case errors.Is(err, fiber.ErrUnauthorized):
 		return fiber.ErrUnauthorized
-	case errors.Is(err, fiber.ErrForbidden):
-		return fiber.ErrForbidden
-	case errors.Is(err, functions.ErrNoRow):
+	case errors.Is(err, fiber.ErrForbidden), errors.Is(err, functions.ErrForbidden):
+		status, response := responses.ErrorForbidden("you are not allowed to access this match")
+		return c.Status(status).JSON(response)
+	case errors.Is(err, functions.ErrEmailNotVerified):
+		status, response := responses.ErrorForbidden("verify your email before sending match requests")
+		return c.Status(status).JSON(response)
+	case errors.Is(err, fiber.ErrNotFound), errors.Is(err, functions.ErrNoRow):
 		status, response := responses.ErrorNotFound("not found")
 		return c.Status(status).JSON(response)
+	case errors.Is(err, fiber.ErrBadRequest):
+		status, response := responses.ErrorBadRequests("bad request")
+		return c.Status(status).JSON(response)
+	case errors.As(err, &violation):
+		status, response := responses.ErrorBadRequests(violation.Reason)
+		return c.Status(status).JSON(response)
+	case errors.Is(err, functions.ErrDuplicateMatch):
+		status, response := responses.ErrorConflict(err.Error())
+		return c.Status(status).JSON(response)
+	case errors.Is(err, functions.ErrInvalidTransition), errors.Is(err, functions.ErrMatchExpired), errors.Is(err, functions.ErrAlreadyMatched),
+		errors.Is(err, functions.ErrTooManyPending):
+		status, response := responses.ErrorBadRequests(err.Error())
+		return c.Status(status).JSON(response)
 	default:
 		validationErrors, ok := err.(validation.Errors)
 		if !ok {
@@ -99,94 +192,198 @@ func (m *MatchHandler) Create(c *fiber.Ctx) error {
 		return c.SendStatus(http.StatusBadRequest)
 	}
 
-	catID, err := strconv.Atoi(payload.UserCatId)
-	if err != nil {
-		return c.SendStatus(http.StatusBadRequest)
+	if err := m.checkVerified(c, userID); err != nil {
+		return m.handleError(c, err)
 	}
 
-	cat, err := m.CatDatabase.FindByIDUser(c.UserContext(), catID, userID)
+	pair, err := m.findPair(c, userID, payload.UserCatId, payload.MatchCatId)
 	if err != nil {
-		if err == functions.ErrNoRow {
-			return m.handleError(c, fiber.ErrNotFound)
-		}
 		return m.handleError(c, err)
 	}
 
-	matchCatID, err := strconv.Atoi(payload.MatchCatId)
+	pair.Reciprocal, err = m.Match.HasPendingReciprocal(c.UserContext(), pair.UserCat.Id, pair.MatchCat.Id)
 	if err != nil {
-		return c.SendStatus(http.StatusBadRequest)
+		return m.handleError(c, err)
 	}
 
-	matchCat, err := m.CatDatabase.FindByID(c.UserContext(), matchCatID)
+	if err := m.Rules.Validate(c.UserContext(), pair); err != nil {
+		return m.handleError(c, err)
+	}
+
+	matchCatSnapshot := newCatSnapshot(pair.MatchCat)
+	userCatSnapshot := newCatSnapshot(pair.UserCat)
+
+	// a reverse request already pending is approved instead, the response then
+	// carries that match with the approved status
+	created, err := m.Match.Create(c.UserContext(), models.Match{
+		UserId:           userID,
+		MatchUserId:      pair.MatchCat.UserId,
+		MatchCatId:       pair.MatchCat.Id,
+		UserCatId:        pair.UserCat.Id,
+		Message:          payload.Message,
+		MatchCatSnapshot: &matchCatSnapshot,
+		UserCatSnapshot:  &userCatSnapshot,
+	})
 	if err != nil {
-		if err == functions.ErrNoRow {
-			return m.handleError(c, fiber.ErrNotFound)
-		}
 		return m.handleError(c, err)
 	}
 
-	//400 if both matchCatId &userCatId already matched
-	if cat.HasMatched {
-		return c.SendStatus(http.StatusBadRequest)
+	if created.Status == functions.MatchStatusApproved {
+		m.mailMatch(created.Id, models.NotificationMatchApproved)
+	} else {
+		m.mailMatch(created.Id, models.NotificationMatchRequested)
 	}
-	if matchCat.HasMatched {
-		return c.SendStatus(http.StatusBadRequest)
+
+	return c.Status(http.StatusCreated).JSON(map[string]interface{}{
+		"message": "success",
+		"data": map[string]interface{}{
+			"id":        strconv.Itoa(created.Id),
+			"status":    created.Status,
+			"createdAt": created.CreatedAt.Format(time.RFC3339),
+		},
+	})
+}
+
+// Check is a dry run of Create, it reports every match rule the pair passes or fails.
+func (m *MatchHandler) Check(c *fiber.Ctx) error {
+	userIDClaim := c.Locals("user_id").(string)
+	userID, err := strconv.Atoi(userIDClaim)
+	if err != nil {
+		return c.SendStatus(http.StatusUnauthorized)
 	}
 
-	//400 if the cat’s gender is same
-	if cat.Sex == matchCat.Sex {
-		return c.SendStatus(http.StatusBadRequest)
+	var payload struct {
+		MatchCatId string `json:"matchCatId"`
+		UserCatId  string `json:"userCatId"`
 	}
 
-	//400 if matchCatId & userCatId is from the same owner
-	if cat.UserId == matchCat.UserId {
+	if err := c.BodyParser(&payload); err != nil {
 		return c.SendStatus(http.StatusBadRequest)
 	}
 
-	matchCatIdInt, err := strconv.Atoi(payload.MatchCatId)
+	pair, err := m.findPair(c, userID, payload.UserCatId, payload.MatchCatId)
 	if err != nil {
-		return c.SendStatus(http.StatusBadRequest)
+		return m.handleError(c, err)
 	}
-	userCatIdInt, err := strconv.Atoi(payload.UserCatId)
+
+	pair.Reciprocal, err = m.Match.HasPendingReciprocal(c.UserContext(), pair.UserCat.Id, pair.MatchCat.Id)
 	if err != nil {
-		return c.SendStatus(http.StatusBadRequest)
+		return m.handleError(c, err)
 	}
 
-	if err := m.Match.Create(c.UserContext(), models.Match{
-		UserId:      userID,
-		MatchUserId: matchCat.UserId,
-		MatchCatId:  matchCatIdInt,
-		UserCatId:   userCatIdInt,
-		Message:     payload.Message,
-	}); err != nil {
-		return c.SendStatus(http.StatusInternalServerError)
+	results, err := m.Rules.Check(c.UserContext(), pair)
+	if err != nil {
+		return m.handleError(c, err)
+	}
+
+	allowed := true
+	for _, result := range results {
+		allowed = allowed && result.Passed
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "success",
+		"data": map[string]interface{}{
+			"allowed": allowed,
+			"rules":   results,
+		},
+	})
+}
+
+// parseMatchID reads the id of a match from the request, a non numeric id is a
+// bad request while an unknown one is left to the lookup to report as not found.
+func parseMatchID(value string) (int, error) {
+	id, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fiber.ErrBadRequest
 	}
 
-	return c.SendStatus(http.StatusCreated)
+	return id, nil
 }
 
-func (m *MatchHandler) getIssuerDetail(c *fiber.Ctx, issuerId int) (MatchIssuer, error) {
+// checkVerified refuses users with an unverified email when the server requires
+// verified emails to send match requests.
+func (m *MatchHandler) checkVerified(c *fiber.Ctx, userID int) error {
+	if !m.RequireVerifiedEmail {
+		return nil
+	}
 
-	user, err := m.UserDatabase.GetUserById(c.UserContext(), strconv.Itoa(issuerId))
+	usr, err := m.UserDatabase.GetUserById(c.UserContext(), strconv.Itoa(userID))
 	if err != nil {
-		return MatchIssuer{}, c.SendStatus(http.StatusInternalServerError)
+		return err
 	}
 
-	return MatchIssuer{
-		Name:      user.Name,
-		Email:     user.Email,
-		CreatedAt: user.CreatedAt.Format(time.RFC3339),
-	}, nil
+	if usr.EmailVerifiedAt == nil {
+		return functions.ErrEmailNotVerified
+	}
+
+	return nil
 }
 
-func (m *MatchHandler) getCatDetail(c *fiber.Ctx, catId int) (CatDetailResponse, error) {
+// findPair loads the cat the user offers, which they must own, and the cat they ask for.
+func (m *MatchHandler) findPair(c *fiber.Ctx, userID int, userCatId, matchCatId string) (match.Pair, error) {
+	catID, err := strconv.Atoi(userCatId)
+	if err != nil {
+		return match.Pair{}, fiber.ErrBadRequest
+	}
+
+	cat, err := m.CatDatabase.FindByIDUser(c.UserContext(), catID, userID)
+	if err != nil {
+		return match.Pair{}, err
+	}
+
+	matchCatID, err := strconv.Atoi(matchCatId)
+	if err != nil {
+		return match.Pair{}, fiber.ErrBadRequest
+	}
 
-	cat, err := m.CatDatabase.FindByID(c.UserContext(), catId)
+	matchCat, err := m.CatDatabase.FindByID(c.UserContext(), matchCatID)
 	if err != nil {
-		return CatDetailResponse{}, c.SendStatus(http.StatusInternalServerError)
+		return match.Pair{}, err
+	}
+
+	return match.Pair{UserCat: cat, MatchCat: matchCat}, nil
+}
+
+// newCatSnapshot freezes the state of a cat when a match request is sent.
+func newCatSnapshot(cat models.Cat) models.CatSnapshot {
+	return models.CatSnapshot{
+		Id:          cat.Id,
+		Name:        cat.Name,
+		Race:        cat.Race,
+		Sex:         cat.Sex,
+		AgeInMonth:  cat.AgeInMonth,
+		Description: cat.Description,
+		ImageUrls:   cat.ImageUrls,
+		HasMatched:  cat.HasMatched,
+		CreatedAt:   cat.CreatedAt,
 	}
+}
 
+func convertCatSnapshotToDetailResponse(snapshot models.CatSnapshot) CatDetailResponse {
 	return CatDetailResponse{
+		Id:          strconv.Itoa(snapshot.Id),
+		Name:        snapshot.Name,
+		Race:        snapshot.Race,
+		Sex:         snapshot.Sex,
+		AgeInMonth:  snapshot.AgeInMonth,
+		Description: snapshot.Description,
+		ImageUrls:   snapshot.ImageUrls,
+		HasMatched:  snapshot.HasMatched,
+		CreatedAt:   snapshot.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func convertUserToMatchIssuer(user models.User) MatchIssuer {
+	return MatchIssuer{
+		Name:      user.Name,
+		Email:     user.Email,
+		CreatedAt: user.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+func convertCatToMatchCatDetail(cat models.Cat) CatDetailResponse {
+	detail := CatDetailResponse{
 		Id:          strconv.Itoa(cat.Id),
 		Name:        cat.Name,
 		Race:        cat.Race,
@@ -196,46 +393,126 @@ func (m *MatchHandler) getCatDetail(c *fiber.Ctx, catId int) (CatDetailResponse,
 		ImageUrls:   cat.ImageUrls,
 		HasMatched:  cat.HasMatched,
 		CreatedAt:   cat.CreatedAt.Format(time.RFC3339),
-	}, nil
+	}
+
+	// deleted cats are still shown in the matches they took part in
+	if cat.DeletedAt != nil {
+		detail.DeletedAt = cat.DeletedAt.Format(time.RFC3339)
+	}
+
+	return detail
 }
 
-func (m *MatchHandler) convertMatchModelToDetailResponse(c *fiber.Ctx, match models.Match) (MatchDetailResponse, error) {
+// useSnapshot reports whether a cat is shown from its snapshot rather than live.
+func useSnapshot(snapshot *models.CatSnapshot, view string) bool {
+	return view == matchViewSnapshot && snapshot != nil
+}
 
-	MatchIssuerDetail, err := m.getIssuerDetail(c, match.UserId)
-	if err != nil {
-		return MatchDetailResponse{}, err
+// matchCatView picks the snapshot of a cat when requested and available, and
+// falls back to the live cat otherwise.
+func matchCatView(catId int, snapshot *models.CatSnapshot, view string, cats map[int]models.Cat) (CatDetailResponse, error) {
+	if useSnapshot(snapshot, view) {
+		return convertCatSnapshotToDetailResponse(*snapshot), nil
+	}
+
+	cat, ok := cats[catId]
+	if !ok {
+		return CatDetailResponse{}, fmt.Errorf("cat %d of match not found", catId)
+	}
+
+	return convertCatToMatchCatDetail(cat), nil
+}
+
+func convertMatchModelToDetailResponse(
+	match models.Match,
+	view string,
+	users map[int]models.User,
+	cats map[int]models.Cat,
+) (MatchDetailResponse, error) {
+	issuer, ok := users[match.UserId]
+	if !ok {
+		return MatchDetailResponse{}, fmt.Errorf("issuer %d of match not found", match.UserId)
 	}
 
-	MatchCatRes, err := m.getCatDetail(c, match.MatchCatId)
+	MatchCatRes, err := matchCatView(match.MatchCatId, match.MatchCatSnapshot, view, cats)
 	if err != nil {
 		return MatchDetailResponse{}, err
 	}
 
-	UserCatRes, err := m.getCatDetail(c, match.UserCatId)
+	UserCatRes, err := matchCatView(match.UserCatId, match.UserCatSnapshot, view, cats)
 	if err != nil {
 		return MatchDetailResponse{}, err
 	}
 
-	return MatchDetailResponse{
+	detail := MatchDetailResponse{
 		Id:             match.Id,
-		Issuedby:       MatchIssuerDetail,
+		Issuedby:       convertUserToMatchIssuer(issuer),
 		MatchCatDetail: MatchCatRes,
 		UserCatDetail:  UserCatRes,
 		Message:        match.Message,
+		Status:         match.Status,
 		CreatedAt:      match.CreatedAt.Format(time.RFC3339),
-	}, nil
+	}
+
+	if match.ExpiresAt != nil {
+		detail.ExpiresAt = match.ExpiresAt.Format(time.RFC3339)
+	}
+
+	return detail, nil
 }
 
-func (m *MatchHandler) convertMatchesToGetMatchesResponse(
-	c *fiber.Ctx,
+type (
+	// matchUserLoader and matchCatLoader load the issuers and cats shown in match
+	// listings, keyed by id.
+	matchUserLoader interface {
+		GetUsersByIds(ctx context.Context, userIDs []int) (map[int]models.User, error)
+	}
+
+	matchCatLoader interface {
+		FindByIDsWithDeleted(ctx context.Context, catIDs []int) (map[int]models.Cat, error)
+	}
+)
+
+// convertMatchesToGetMatchesResponse loads the issuers and cats of every match in
+// two batched queries, whatever the number of matches.
+func convertMatchesToGetMatchesResponse(
+	ctx context.Context,
+	users matchUserLoader,
+	cats matchCatLoader,
 	matches []models.Match,
+	view string,
 ) ([]MatchDetailResponse, error) {
-	var result []MatchDetailResponse
+	var (
+		userIds = []int{}
+		catIds  = []int{}
+	)
+
 	for _, match := range matches {
+		userIds = append(userIds, match.UserId)
+
+		if !useSnapshot(match.MatchCatSnapshot, view) {
+			catIds = append(catIds, match.MatchCatId)
+		}
+		if !useSnapshot(match.UserCatSnapshot, view) {
+			catIds = append(catIds, match.UserCatId)
+		}
+	}
 
-		MatchDetail, err := m.convertMatchModelToDetailResponse(c, match)
+	issuers, err := users.GetUsersByIds(ctx, userIds)
+	if err != nil {
+		return nil, err
+	}
+
+	matchCats, err := cats.FindByIDsWithDeleted(ctx, catIds)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []MatchDetailResponse{}
+	for _, match := range matches {
+		MatchDetail, err := convertMatchModelToDetailResponse(match, view, issuers, matchCats)
 		if err != nil {
-			return result, err
+			return nil, err
 		}
 
 		result = append(result, MatchDetail)
@@ -245,25 +522,103 @@ func (m *MatchHandler) convertMatchesToGetMatchesResponse(
 }
 
 func (m *MatchHandler) Get(c *fiber.Ctx) error {
-	userId := c.Locals("user_id").(string)
+	userIDClaim := c.Locals("user_id").(string)
+	userID, err := strconv.Atoi(userIDClaim)
+	if err != nil {
+		return c.SendStatus(http.StatusUnauthorized)
+	}
+
+	// view=snapshot shows the cats as they were when the request was sent
+	view := c.Query("view", matchViewLive)
+	if view != matchViewLive && view != matchViewSnapshot {
+		status, response := responses.ErrorBadRequests("view should be either live or snapshot")
+		return c.Status(status).JSON(response)
+	}
+
+	var filter QueryFilterGetMatches
+	if err := c.QueryParser(&filter); err != nil {
+		return m.handleError(c, fiber.ErrBadRequest)
+	}
 
-	matches, err := m.Match.GetRelatedMatches(c.UserContext(), userId)
+	if err := filter.Validate(); err != nil {
+		return m.handleError(c, err)
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = 10
+	}
+
+	filterDB := models.FilterGetMatches{
+		Direction: filter.Direction,
+		Statuses:  filter.statuses(),
+		Limit:     filter.Limit,
+		Offset:    filter.Offset,
+	}
+
+	if filter.CatId != "" {
+		filterDB.CatId, _ = strconv.Atoi(filter.CatId)
+	}
+
+	if filter.CreatedFrom != "" {
+		createdFrom, _ := time.Parse(time.RFC3339, filter.CreatedFrom)
+		filterDB.CreatedFrom = &createdFrom
+	}
+
+	if filter.CreatedTo != "" {
+		createdTo, _ := time.Parse(time.RFC3339, filter.CreatedTo)
+		filterDB.CreatedTo = &createdTo
+	}
+
+	if filter.Cursor != "" {
+		filterDB.Cursor, err = decodeCursor(filter.Cursor)
+		if err != nil {
+			return m.handleError(c, validation.Errors{"cursor": errors.New("is not a valid cursor")})
+		}
+		// offset is meaningless when paging with a cursor
+		filterDB.Offset = 0
+	}
+
+	matches, err := m.Match.FindAll(c.UserContext(), filterDB, userID)
 	if err != nil {
-		return c.SendStatus(http.StatusInternalServerError)
+		return m.handleError(c, err)
 	}
 
-	matchesResponse, err := m.convertMatchesToGetMatchesResponse(c, matches)
+	total, err := m.Match.Count(c.UserContext(), filterDB, userID)
 	if err != nil {
-		return err
+		return m.handleError(c, err)
+	}
+
+	matchesResponse, err := convertMatchesToGetMatchesResponse(c.UserContext(), m.UserDatabase, m.CatDatabase, matches, view)
+	if err != nil {
+		return m.handleError(c, err)
+	}
+
+	meta := Meta{
+		Limit:  filterDB.Limit,
+		Offset: filterDB.Offset,
+		Total:  total,
+	}
+
+	// a full page means there may be more matches after the last one
+	if len(matches) > 0 && len(matches) == filterDB.Limit {
+		last := matches[len(matches)-1]
+		meta.NextCursor = encodeCursor(last.CreatedAt, last.Id)
 	}
 
 	return c.JSON(map[string]interface{}{
 		"message": "success",
 		"data":    matchesResponse,
+		"meta":    meta,
 	})
 }
 
 func (m *MatchHandler) Approve(c *fiber.Ctx) error {
+	userIDClaim := c.Locals("user_id").(string)
+	userID, err := strconv.Atoi(userIDClaim)
+	if err != nil {
+		return c.SendStatus(http.StatusUnauthorized)
+	}
+
 	var payload struct {
 		MatchId string `json:"matchId"`
 	}
@@ -272,151 +627,162 @@ func (m *MatchHandler) Approve(c *fiber.Ctx) error {
 		return c.SendStatus(http.StatusBadRequest)
 	}
 
-	match, err := m.Match.GetMatchById(c.UserContext(), payload.MatchId)
+	matchID, err := parseMatchID(payload.MatchId)
 	if err != nil {
-		if err == functions.ErrNoRow {
-			return m.handleError(c, fiber.ErrNotFound)
-		}
 		return m.handleError(c, err)
 	}
 
-	if match.Status == "removed" || match.Status == "approved" {
-		return m.handleError(c, fiber.ErrBadRequest)
-	}
-
-	err = m.Match.UpdateStatus(c.UserContext(), match, "approved")
+	pending, err := m.Match.GetMatchById(c.UserContext(), matchID)
 	if err != nil {
-		if err == functions.ErrNoRow {
-			return m.handleError(c, fiber.ErrNotFound)
-		}
 		return m.handleError(c, err)
 	}
 
-	userCat, err := m.CatDatabase.FindByID(c.UserContext(), match.UserCatId)
+	if pending.MatchUserId != userID {
+		return m.handleError(c, functions.ErrForbidden)
+	}
+
+	cats, err := m.CatDatabase.FindByIDsWithDeleted(c.UserContext(), []int{pending.UserCatId, pending.MatchCatId})
 	if err != nil {
-		if err == functions.ErrNoRow {
-			return m.handleError(c, fiber.ErrNotFound)
-		}
 		return m.handleError(c, err)
 	}
 
-	userCat.HasMatched = true
+	// the owners may have blocked each other since the request was sent
+	pair := match.Pair{UserCat: cats[pending.UserCatId], MatchCat: cats[pending.MatchCatId]}
+	if err := m.ApprovalRules.Validate(c.UserContext(), pair); err != nil {
+		return m.handleError(c, err)
+	}
 
-	err = m.CatDatabase.Update(c.UserContext(), userCat)
+	err = m.Match.Approve(c.UserContext(), matchID, userID)
 	if err != nil {
-		if err == functions.ErrNoRow {
-			return m.handleError(c, fiber.ErrNotFound)
-		}
 		return m.handleError(c, err)
 	}
 
-	matchCat, err := m.CatDatabase.FindByID(c.UserContext(), match.MatchCatId)
+	m.mailMatch(matchID, models.NotificationMatchApproved)
+
+	return c.SendStatus(http.StatusOK)
+}
+
+func (m *MatchHandler) Reject(c *fiber.Ctx) error {
+	userIDClaim := c.Locals("user_id").(string)
+	userID, err := strconv.Atoi(userIDClaim)
 	if err != nil {
-		if err == functions.ErrNoRow {
-			return m.handleError(c, fiber.ErrNotFound)
-		}
-		return m.handleError(c, err)
+		return c.SendStatus(http.StatusUnauthorized)
 	}
 
-	matchCat.HasMatched = true
+	var payload struct {
+		MatchId string `json:"matchId"`
+	}
+
+	if err := c.BodyParser(&payload); err != nil {
+		return c.SendStatus(http.StatusBadRequest)
+	}
 
-	err = m.CatDatabase.Update(c.UserContext(), matchCat)
+	matchID, err := parseMatchID(payload.MatchId)
 	if err != nil {
-		if err == functions.ErrNoRow {
-			return m.handleError(c, fiber.ErrNotFound)
-		}
 		return m.handleError(c, err)
 	}
 
-	userCatMatches, err := m.Match.GetRelatedCatMatches(c.UserContext(), match.UserCatId)
+	err = m.Match.Reject(c.UserContext(), matchID, userID)
 	if err != nil {
-		if err == functions.ErrNoRow {
-			return m.handleError(c, fiber.ErrNotFound)
-		}
 		return m.handleError(c, err)
 	}
 
-	for _, match := range userCatMatches {
+	return c.SendStatus(http.StatusOK)
+}
 
-		err = m.Match.UpdateStatus(c.UserContext(), match, "removed")
-		if err != nil {
-			if err == functions.ErrNoRow {
-				return m.handleError(c, fiber.ErrNotFound)
-			}
-			return m.handleError(c, err)
-		}
+// Delete withdraws a pending match request, the match itself is kept for its history.
+func (m *MatchHandler) Delete(c *fiber.Ctx) error {
+	userIDClaim := c.Locals("user_id").(string)
+	userID, err := strconv.Atoi(userIDClaim)
+	if err != nil {
+		return c.SendStatus(http.StatusUnauthorized)
 	}
 
-	matchCatMatches, err := m.Match.GetRelatedCatMatches(c.UserContext(), match.MatchCatId)
+	matchID, err := parseMatchID(c.Params("id"))
 	if err != nil {
-		if err == functions.ErrNoRow {
-			return m.handleError(c, fiber.ErrNotFound)
-		}
 		return m.handleError(c, err)
 	}
 
-	for _, match := range matchCatMatches {
-
-		err = m.Match.UpdateStatus(c.UserContext(), match, "removed")
-		if err != nil {
-			if err == functions.ErrNoRow {
-				return m.handleError(c, fiber.ErrNotFound)
-			}
-			return m.handleError(c, err)
-		}
+	err = m.Match.Withdraw(c.UserContext(), matchID, userID)
+	if err != nil {
+		return m.handleError(c, err)
 	}
 
 	return c.SendStatus(http.StatusOK)
 }
 
-func (m *MatchHandler) Reject(c *fiber.Ctx) error {
-	var payload struct {
-		MatchId string `json:"matchId"`
+// Reissue reopens an expired match request, the cats are checked and snapshotted again.
+func (m *MatchHandler) Reissue(c *fiber.Ctx) error {
+	userIDClaim := c.Locals("user_id").(string)
+	userID, err := strconv.Atoi(userIDClaim)
+	if err != nil {
+		return c.SendStatus(http.StatusUnauthorized)
 	}
 
-	if err := c.BodyParser(&payload); err != nil {
-		return c.SendStatus(http.StatusBadRequest)
+	matchID, err := parseMatchID(c.Params("id"))
+	if err != nil {
+		return m.handleError(c, err)
 	}
 
-	match, err := m.Match.GetMatchById(c.UserContext(), payload.MatchId)
+	expired, err := m.Match.GetMatchById(c.UserContext(), matchID)
 	if err != nil {
-		if err == functions.ErrNoRow {
-			return m.handleError(c, fiber.ErrNotFound)
-		}
 		return m.handleError(c, err)
 	}
 
-	if match.Status == "removed" || match.Status == "approved" {
-		return m.handleError(c, fiber.ErrBadRequest)
+	if expired.UserId != userID {
+		return m.handleError(c, functions.ErrForbidden)
 	}
 
-	err = m.Match.UpdateStatus(c.UserContext(), match, "removed")
+	if err := m.checkVerified(c, userID); err != nil {
+		return m.handleError(c, err)
+	}
+
+	pair, err := m.findPair(c, userID, strconv.Itoa(expired.UserCatId), strconv.Itoa(expired.MatchCatId))
+	if err != nil {
+		return m.handleError(c, err)
+	}
+
+	if err := m.Rules.Validate(c.UserContext(), pair); err != nil {
+		return m.handleError(c, err)
+	}
+
+	err = m.Match.Reissue(c.UserContext(), matchID, userID, newCatSnapshot(pair.MatchCat), newCatSnapshot(pair.UserCat))
 	if err != nil {
-		if err == functions.ErrNoRow {
-			return m.handleError(c, fiber.ErrNotFound)
-		}
 		return m.handleError(c, err)
 	}
 
 	return c.SendStatus(http.StatusOK)
 }
 
-func (m *MatchHandler) Delete(c *fiber.Ctx) error {
-	userId := c.Locals("user_id").(string)
-	matchId := c.Params("id")
+// History returns every status change of a match, only its issuer and receiver can see it.
+func (m *MatchHandler) History(c *fiber.Ctx) error {
+	userIDClaim := c.Locals("user_id").(string)
+	userID, err := strconv.Atoi(userIDClaim)
+	if err != nil {
+		return c.SendStatus(http.StatusUnauthorized)
+	}
 
-	err := m.Match.Delete(c.UserContext(), userId, matchId)
+	matchID, err := parseMatchID(c.Params("id"))
 	if err != nil {
-		if errors.Is(err, functions.ErrNoRow) {
-			return c.SendStatus(http.StatusNotFound)
-		}
+		return m.handleError(c, err)
+	}
 
-		if errors.Is(err, functions.ErrUnauthorized) {
-			return c.SendStatus(http.StatusUnauthorized)
-		}
+	match, err := m.Match.GetMatchById(c.UserContext(), matchID)
+	if err != nil {
+		return m.handleError(c, err)
+	}
 
-		return c.SendStatus(http.StatusInternalServerError)
+	if match.UserId != userID && match.MatchUserId != userID {
+		return m.handleError(c, functions.ErrForbidden)
 	}
 
-	return c.SendStatus(http.StatusOK)
+	events, err := m.Match.GetEvents(c.UserContext(), matchID)
+	if err != nil {
+		return m.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "success",
+		"data":    events,
+	})
 }
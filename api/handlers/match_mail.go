@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"CatsSocial/db/models"
+	"CatsSocial/mailer"
+	"context"
+	"log"
+)
+
+type matchMailData struct {
+	RecipientName string
+	ActorName     string
+	UserCatName   string
+	MatchCatName  string
+	Message       string
+}
+
+// mailMatch emails the other party of a match that was requested or approved,
+// in the background so a slow mail server never holds up the response. Failures
+// are only logged and the email is dropped when too many are in flight, the match
+// itself already went through.
+func (m *MatchHandler) mailMatch(matchId int, kind string) {
+	if m.Mailer == nil {
+		return
+	}
+
+	err := goMail(func(ctx context.Context) {
+		if err := m.sendMatchMail(ctx, matchId, kind); err != nil {
+			log.Printf("failed email match %d: %v", matchId, err)
+		}
+	})
+	if err != nil {
+		log.Printf("dropped email of match %d: %v", matchId, err)
+	}
+}
+
+func (m *MatchHandler) sendMatchMail(ctx context.Context, matchId int, kind string) error {
+	match, err := m.Match.GetMatchById(ctx, matchId)
+	if err != nil {
+		return err
+	}
+
+	// requests go to the receiver, answers to the issuer
+	recipientId, actorId := match.MatchUserId, match.UserId
+	if kind == models.NotificationMatchApproved {
+		recipientId, actorId = match.UserId, match.MatchUserId
+	}
+
+	users, err := m.UserDatabase.GetUsersByIds(ctx, []int{recipientId, actorId})
+	if err != nil {
+		return err
+	}
+
+	recipient, ok := users[recipientId]
+	if !ok || !recipient.EmailNotifications {
+		return nil
+	}
+
+	cats, err := m.CatDatabase.FindByIDsWithDeleted(ctx, []int{match.UserCatId, match.MatchCatId})
+	if err != nil {
+		return err
+	}
+
+	msg, err := mailer.Render(kind, matchMailData{
+		RecipientName: recipient.Name,
+		ActorName:     users[actorId].Name,
+		UserCatName:   cats[match.UserCatId].Name,
+		MatchCatName:  cats[match.MatchCatId].Name,
+		Message:       match.Message,
+	})
+	if err != nil {
+		return err
+	}
+
+	msg.To = recipient.Email
+
+	return m.Mailer.Send(ctx, msg)
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"CatsSocial/db/models"
+	"context"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// queryLatency stands in for the round trip of one database query.
+const queryLatency = 50 * time.Microsecond
+
+// fakeMatchStore answers the loaders from memory, every call costs one round trip.
+type fakeMatchStore struct {
+	users   map[int]models.User
+	cats    map[int]models.Cat
+	queries int
+}
+
+func newFakeMatchStore(matches []models.Match) *fakeMatchStore {
+	store := &fakeMatchStore{
+		users: map[int]models.User{},
+		cats:  map[int]models.Cat{},
+	}
+
+	for _, match := range matches {
+		store.users[match.UserId] = models.User{Id: fmt.Sprint(match.UserId), Name: "owner", Email: "owner@example.com"}
+		for _, id := range []int{match.MatchCatId, match.UserCatId} {
+			store.cats[id] = models.Cat{Id: id, Name: "cat", Race: "Persian", Sex: "male", ImageUrls: []string{}}
+		}
+	}
+
+	return store
+}
+
+func (f *fakeMatchStore) GetUsersByIds(ctx context.Context, userIDs []int) (map[int]models.User, error) {
+	f.queries++
+	time.Sleep(queryLatency)
+
+	users := make(map[int]models.User, len(userIDs))
+	for _, id := range userIDs {
+		if user, ok := f.users[id]; ok {
+			users[id] = user
+		}
+	}
+
+	return users, nil
+}
+
+func (f *fakeMatchStore) FindByIDsWithDeleted(ctx context.Context, catIDs []int) (map[int]models.Cat, error) {
+	f.queries++
+	time.Sleep(queryLatency)
+
+	cats := make(map[int]models.Cat, len(catIDs))
+	for _, id := range catIDs {
+		if cat, ok := f.cats[id]; ok {
+			cats[id] = cat
+		}
+	}
+
+	return cats, nil
+}
+
+// convertMatchesOneByOne is the N+1 way of listing matches, one query for the
+// issuer and one for each cat of every match.
+func convertMatchesOneByOne(ctx context.Context, users matchUserLoader, cats matchCatLoader, matches []models.Match, view string) ([]MatchDetailResponse, error) {
+	result := []MatchDetailResponse{}
+
+	for _, match := range matches {
+		issuer, err := users.GetUsersByIds(ctx, []int{match.UserId})
+		if err != nil {
+			return nil, err
+		}
+
+		matchCats := map[int]models.Cat{}
+		for _, id := range []int{match.MatchCatId, match.UserCatId} {
+			cat, err := cats.FindByIDsWithDeleted(ctx, []int{id})
+			if err != nil {
+				return nil, err
+			}
+			matchCats[id] = cat[id]
+		}
+
+		detail, err := convertMatchModelToDetailResponse(match, view, issuer, matchCats)
+		if err != nil {
+			return nil, err
+		}
+
+		result = append(result, detail)
+	}
+
+	return result, nil
+}
+
+func benchmarkMatches(n int) []models.Match {
+	matches := make([]models.Match, n)
+	for i := range matches {
+		matches[i] = models.Match{
+			Id:          i + 1,
+			UserId:      i%10 + 1,
+			MatchUserId: 100,
+			MatchCatId:  2*i + 1,
+			UserCatId:   2*i + 2,
+			Status:      "pending",
+			CreatedAt:   time.Now(),
+		}
+	}
+
+	return matches
+}
+
+func TestConvertMatchesToGetMatchesResponse(t *testing.T) {
+	matches := benchmarkMatches(20)
+
+	batched := newFakeMatchStore(matches)
+	got, err := convertMatchesToGetMatchesResponse(context.Background(), batched, batched, matches, matchViewLive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oneByOne := newFakeMatchStore(matches)
+	want, err := convertMatchesOneByOne(context.Background(), oneByOne, oneByOne, matches, matchViewLive)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("batched response differs from the one by one response")
+	}
+
+	if batched.queries != 2 {
+		t.Errorf("batched loader made %d queries, want 2", batched.queries)
+	}
+}
+
+func BenchmarkConvertMatchesToGetMatchesResponse(b *testing.B) {
+	loaders := []struct {
+		name string
+		load func(ctx context.Context, users matchUserLoader, cats matchCatLoader, matches []models.Match, view string) ([]MatchDetailResponse, error)
+	}{
+		{"batched", convertMatchesToGetMatchesResponse},
+		{"n+1", convertMatchesOneByOne},
+	}
+
+	for _, size := range []int{5, 20, 100} {
+		matches := benchmarkMatches(size)
+
+		for _, loader := range loaders {
+			b.Run(fmt.Sprintf("%s/%d", loader.name, size), func(b *testing.B) {
+				store := newFakeMatchStore(matches)
+
+				for i := 0; i < b.N; i++ {
+					if _, err := loader.load(context.Background(), store, store, matches, matchViewLive); err != nil {
+						b.Fatal(err)
+					}
+				}
+
+				b.ReportMetric(float64(store.queries)/float64(b.N), "queries/op")
+			})
+		}
+	}
+}
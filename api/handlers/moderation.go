@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"CatsSocial/api/responses"
+	"CatsSocial/db/functions"
+	"CatsSocial/db/models"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/go-ozzo/ozzo-validation/is"
+	"github.com/gofiber/fiber/v2"
+)
+
+type (
+	Moderation struct {
+		Database *functions.Moderation
+	}
+
+	ReportPayload struct {
+		TargetType string `json:"targetType"`
+		TargetId   string `json:"targetId"`
+		Reason     string `json:"reason"`
+	}
+)
+
+func (app ReportPayload) Validate() error {
+	return validation.ValidateStruct(&app,
+		// TargetType should be either "user", "cat" or "match".
+		validation.Field(&app.TargetType, validation.Required, validation.In(models.ReportTargetUser, models.ReportTargetCat, models.ReportTargetMatch)),
+		// TargetId should be the id of the reported user, cat or match.
+		validation.Field(&app.TargetId, validation.Required, is.Int),
+		// Reason cannot be empty, and the length must be between 1 and 500.
+		validation.Field(&app.Reason, validation.Required, validation.Length(1, 500)),
+	)
+}
+
+func (m *Moderation) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, fiber.ErrBadRequest):
+		status, response := responses.ErrorBadRequests("bad request")
+		return c.Status(status).JSON(response)
+	case errors.Is(err, fiber.ErrNotFound), errors.Is(err, functions.ErrNoRow):
+		status, response := responses.ErrorNotFound("not found")
+		return c.Status(status).JSON(response)
+	default:
+		validationErrors, ok := err.(validation.Errors)
+		if !ok {
+			status, response := responses.ErrorServer(err.Error())
+			return c.Status(status).JSON(response)
+		}
+
+		errMessages := []string{}
+		for key, ve := range validationErrors {
+			errMessages = append(errMessages, fmt.Sprintf(
+				"field %s: %s",
+				key,
+				ve.Error()))
+		}
+
+		status, response := responses.ErrorBadRequests(strings.Join(errMessages, ""))
+		return c.Status(status).JSON(response)
+	}
+}
+
+// Block hides the cats of another user from the caller and refuses match requests
+// between them, whoever sends them.
+func (m *Moderation) Block(c *fiber.Ctx) error {
+	userIDClaim := c.Locals("user_id").(string)
+	userID, err := strconv.Atoi(userIDClaim)
+	if err != nil {
+		return c.SendStatus(http.StatusUnauthorized)
+	}
+
+	blockedID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return m.handleError(c, fiber.ErrNotFound)
+	}
+
+	if blockedID == userID {
+		status, response := responses.ErrorBadRequests("you cannot block yourself")
+		return c.Status(status).JSON(response)
+	}
+
+	if err := m.Database.Block(c.UserContext(), userID, blockedID); err != nil {
+		return m.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "success",
+	})
+}
+
+func (m *Moderation) Unblock(c *fiber.Ctx) error {
+	userIDClaim := c.Locals("user_id").(string)
+	userID, err := strconv.Atoi(userIDClaim)
+	if err != nil {
+		return c.SendStatus(http.StatusUnauthorized)
+	}
+
+	blockedID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return m.handleError(c, fiber.ErrNotFound)
+	}
+
+	if err := m.Database.Unblock(c.UserContext(), userID, blockedID); err != nil {
+		return m.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "success",
+	})
+}
+
+// Report puts a user, cat or match in the moderation queue.
+func (m *Moderation) Report(c *fiber.Ctx) error {
+	userIDClaim := c.Locals("user_id").(string)
+	userID, err := strconv.Atoi(userIDClaim)
+	if err != nil {
+		return c.SendStatus(http.StatusUnauthorized)
+	}
+
+	var payload ReportPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return m.handleError(c, fiber.ErrBadRequest)
+	}
+
+	if err := payload.Validate(); err != nil {
+		return m.handleError(c, err)
+	}
+
+	targetID, _ := strconv.Atoi(payload.TargetId)
+
+	report, err := m.Database.Report(c.UserContext(), models.Report{
+		ReporterId: &userID,
+		TargetType: payload.TargetType,
+		TargetId:   targetID,
+		Reason:     payload.Reason,
+	})
+	if err != nil {
+		return m.handleError(c, err)
+	}
+
+	return c.Status(http.StatusCreated).JSON(map[string]interface{}{
+		"message": "success",
+		"data":    report,
+	})
+}
+
+// GetReports lists the moderation queue, only admins can see it.
+func (m *Moderation) GetReports(c *fiber.Ctx) error {
+	var filter struct {
+		Status string `json:"status"`
+		Limit  int    `json:"limit"`
+		Offset int    `json:"offset"`
+	}
+
+	if err := c.QueryParser(&filter); err != nil {
+		return m.handleError(c, fiber.ErrBadRequest)
+	}
+
+	err := validation.ValidateStruct(&filter,
+		validation.Field(&filter.Status, validation.In("open", "resolved", "dismissed")),
+		validation.Field(&filter.Limit, validation.Min(0)),
+		validation.Field(&filter.Offset, validation.Min(0)),
+	)
+	if err != nil {
+		return m.handleError(c, err)
+	}
+
+	if filter.Status == "" {
+		filter.Status = "open"
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = 20
+	}
+
+	reports, err := m.Database.FindReports(c.UserContext(), filter.Status, filter.Limit, filter.Offset)
+	if err != nil {
+		return m.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "success",
+		"data":    reports,
+	})
+}
+
+// ResolveReport closes an open report, only admins can do so.
+func (m *Moderation) ResolveReport(c *fiber.Ctx) error {
+	reportID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return m.handleError(c, fiber.ErrNotFound)
+	}
+
+	var payload struct {
+		Status string `json:"status"`
+	}
+
+	if err := c.BodyParser(&payload); err != nil {
+		return m.handleError(c, fiber.ErrBadRequest)
+	}
+
+	err = validation.ValidateStruct(&payload,
+		validation.Field(&payload.Status, validation.Required, validation.In("resolved", "dismissed")),
+	)
+	if err != nil {
+		return m.handleError(c, err)
+	}
+
+	if err := m.Database.ResolveReport(c.UserContext(), reportID, payload.Status); err != nil {
+		return m.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "success",
+	})
+}
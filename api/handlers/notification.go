@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"CatsSocial/api/responses"
+	"CatsSocial/db/functions"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/gofiber/fiber/v2"
+)
+
+type (
+	NotificationHandler struct {
+		Database *functions.Notification
+	}
+
+	NotificationMeta struct {
+		Limit  int `json:"limit"`
+		Offset int `json:"offset"`
+		Unread int `json:"unread"`
+	}
+)
+
+func (n *NotificationHandler) handleError(c *fiber.Ctx, err error) error {
+	validationErrors, ok := err.(validation.Errors)
+	if !ok {
+		status, response := responses.ErrorServer(err.Error())
+		return c.Status(status).JSON(response)
+	}
+
+	errMessages := []string{}
+	for key, ve := range validationErrors {
+		errMessages = append(errMessages, fmt.Sprintf(
+			"field %s: %s",
+			key,
+			ve.Error()))
+	}
+
+	status, response := responses.ErrorBadRequests(strings.Join(errMessages, ""))
+	return c.Status(status).JSON(response)
+}
+
+// Get lists the notifications of the user, newest first, with their unread count.
+func (n *NotificationHandler) Get(c *fiber.Ctx) error {
+	userIDClaim := c.Locals("user_id").(string)
+	userID, err := strconv.Atoi(userIDClaim)
+	if err != nil {
+		return c.SendStatus(http.StatusUnauthorized)
+	}
+
+	var filter struct {
+		Unread bool `json:"unread"`
+		Limit  int  `json:"limit"`
+		Offset int  `json:"offset"`
+	}
+
+	if err := c.QueryParser(&filter); err != nil {
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	err = validation.ValidateStruct(&filter,
+		validation.Field(&filter.Limit, validation.Min(0)),
+		validation.Field(&filter.Offset, validation.Min(0)),
+	)
+	if err != nil {
+		return n.handleError(c, err)
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = 20
+	}
+
+	notifications, err := n.Database.FindAll(c.UserContext(), userID, filter.Unread, filter.Limit, filter.Offset)
+	if err != nil {
+		return n.handleError(c, err)
+	}
+
+	unread, err := n.Database.CountUnread(c.UserContext(), userID)
+	if err != nil {
+		return n.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "success",
+		"data":    notifications,
+		"meta": NotificationMeta{
+			Limit:  filter.Limit,
+			Offset: filter.Offset,
+			Unread: unread,
+		},
+	})
+}
+
+// Read marks the given notifications as read, or every notification when no id is given.
+func (n *NotificationHandler) Read(c *fiber.Ctx) error {
+	userIDClaim := c.Locals("user_id").(string)
+	userID, err := strconv.Atoi(userIDClaim)
+	if err != nil {
+		return c.SendStatus(http.StatusUnauthorized)
+	}
+
+	var payload struct {
+		Ids []int `json:"ids"`
+	}
+
+	// an empty body marks everything read
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&payload); err != nil {
+			return c.SendStatus(http.StatusBadRequest)
+		}
+	}
+
+	if err := n.Database.MarkRead(c.UserContext(), userID, payload.Ids); err != nil {
+		return n.handleError(c, err)
+	}
+
+	unread, err := n.Database.CountUnread(c.UserContext(), userID)
+	if err != nil {
+		return n.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "success",
+		"data": map[string]interface{}{
+			"unread": unread,
+		},
+	})
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"CatsSocial/stream"
+	"bufio"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// streamHeartbeat keeps idle streams from being closed by proxies.
+const streamHeartbeat = 15 * time.Second
+
+type Stream struct {
+	Hub *stream.Hub
+}
+
+// Get streams the notifications of the user as server sent events until the
+// client goes away.
+func (s *Stream) Get(c *fiber.Ctx) error {
+	userIDClaim := c.Locals("user_id").(string)
+	userID, err := strconv.Atoi(userIDClaim)
+	if err != nil {
+		return c.SendStatus(http.StatusUnauthorized)
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	// nginx would otherwise buffer the events
+	c.Set("X-Accel-Buffering", "no")
+
+	events, unsubscribe := s.Hub.Subscribe(userID)
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer unsubscribe()
+
+		heartbeat := time.NewTicker(streamHeartbeat)
+		defer heartbeat.Stop()
+
+		fmt.Fprint(w, ": connected\n\n")
+
+		for {
+			// a failed flush is the only sign that the client disconnected
+			if err := w.Flush(); err != nil {
+				return
+			}
+
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Id, event.Type, event.Data)
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": ping\n\n")
+			}
+		}
+	})
+
+	return nil
+}
@@ -2,18 +2,33 @@ package handlers
 
 import (
 	"CatsSocial/api/responses"
+	"CatsSocial/auth"
 	"CatsSocial/db/functions"
 	"CatsSocial/db/models"
-	"CatsSocial/utils"
+	"CatsSocial/mailer"
+	"CatsSocial/storage"
 	"errors"
 	"net/http"
 	"net/mail"
+	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 )
 
 type User struct {
-	Database *functions.User
+	Database    *functions.User
+	Tokens      *functions.Token
+	Auth        *auth.Auth
+	CatDatabase *functions.Cat
+	Storage     storage.Storage
+	// Mailer sends the verification and password reset emails, nil turns them off.
+	Mailer mailer.Mailer
+	// AppURL is where the mailed links point to, without it only the token is mailed.
+	AppURL string
+
+	VerifyTokenTTL time.Duration
+	ResetTokenTTL  time.Duration
 }
 
 func validateUser(req struct {
@@ -79,6 +94,26 @@ func validateLogin(req struct {
 	return nil
 }
 
+// issueTokens generates an access token together with a refresh token starting a new token family.
+func (u *User) issueTokens(ctx *fiber.Ctx, usr models.User) (string, string, error) {
+	accessToken, err := u.Auth.Issue(usr.Email, usr.Id)
+	if err != nil {
+		return "", "", err
+	}
+
+	userID, err := strconv.Atoi(usr.Id)
+	if err != nil {
+		return "", "", err
+	}
+
+	refreshToken, err := u.Tokens.CreateRefreshToken(ctx.UserContext(), userID)
+	if err != nil {
+		return "", "", err
+	}
+
+	return accessToken, refreshToken, nil
+}
+
 func (u *User) Register(ctx *fiber.Ctx) error {
 	// Parse request body
 	var req struct {
@@ -115,19 +150,22 @@ func (u *User) Register(ctx *fiber.Ctx) error {
 		return ctx.Status(status).JSON(response)
 	}
 
-	// generate access token
-	accessToken, err := utils.GenerateAccessToken(result.Email, result.Id)
+	// generate access and refresh tokens
+	accessToken, refreshToken, err := u.issueTokens(ctx, result)
 	if err != nil {
 		status, response := responses.ErrorServers(err.Error())
 		return ctx.Status(status).JSON(response)
 	}
 
+	u.mailVerification(result.Id)
+
 	return ctx.Status(fiber.StatusCreated).JSON(fiber.Map{
 		"message": "User registered successfully",
 		"data": fiber.Map{
-			"email":       result.Email,
-			"name":        result.Name,
-			"accessToken": accessToken,
+			"email":        result.Email,
+			"name":         result.Name,
+			"accessToken":  accessToken,
+			"refreshToken": refreshToken,
 		},
 	})
 }
@@ -164,8 +202,8 @@ func (u *User) Login(ctx *fiber.Ctx) error {
 		return ctx.Status(status).JSON(response)
 	}
 
-	// generate access token
-	accessToken, err := utils.GenerateAccessToken(result.Email, result.Id)
+	// generate access and refresh tokens
+	accessToken, refreshToken, err := u.issueTokens(ctx, result)
 	if err != nil {
 		status, response := responses.ErrorServers(err.Error())
 		return ctx.Status(status).JSON(response)
@@ -174,9 +212,279 @@ func (u *User) Login(ctx *fiber.Ctx) error {
 	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
 		"message": "User logged successfully",
 		"data": fiber.Map{
-			"email":       result.Email,
-			"name":        result.Name,
-			"accessToken": accessToken,
+			"email":        result.Email,
+			"name":         result.Name,
+			"accessToken":  accessToken,
+			"refreshToken": refreshToken,
 		},
 	})
 }
+
+func (u *User) Refresh(ctx *fiber.Ctx) error {
+	var req struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.SendStatus(http.StatusBadRequest)
+	}
+
+	if len(req.RefreshToken) == 0 {
+		status, response := responses.ErrorBadRequests("refreshToken is required")
+		return ctx.Status(status).JSON(response)
+	}
+
+	userID, refreshToken, err := u.Tokens.Rotate(ctx.UserContext(), req.RefreshToken)
+	if err != nil {
+		if errors.Is(err, functions.ErrTokenInvalid) || errors.Is(err, functions.ErrTokenExpired) || errors.Is(err, functions.ErrTokenReused) {
+			status, response := responses.ErrorUnauthorized(err.Error())
+			return ctx.Status(status).JSON(response)
+		}
+
+		status, response := responses.ErrorServers(err.Error())
+		return ctx.Status(status).JSON(response)
+	}
+
+	result, err := u.Database.GetUserById(ctx.UserContext(), strconv.Itoa(userID))
+	if err != nil {
+		if errors.Is(err, functions.ErrNoRow) {
+			status, response := responses.ErrorUnauthorized("USER_NOT_FOUND")
+			return ctx.Status(status).JSON(response)
+		}
+
+		status, response := responses.ErrorServers(err.Error())
+		return ctx.Status(status).JSON(response)
+	}
+
+	accessToken, err := u.Auth.Issue(result.Email, result.Id)
+	if err != nil {
+		status, response := responses.ErrorServers(err.Error())
+		return ctx.Status(status).JSON(response)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Token refreshed successfully",
+		"data": fiber.Map{
+			"accessToken":  accessToken,
+			"refreshToken": refreshToken,
+		},
+	})
+}
+
+func (u *User) Logout(ctx *fiber.Ctx) error {
+	userID, err := strconv.Atoi(ctx.Locals("user_id").(string))
+	if err != nil {
+		return ctx.SendStatus(http.StatusUnauthorized)
+	}
+
+	// the refresh token is optional, without it only the access token is revoked
+	var req struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&req); err != nil {
+			return ctx.SendStatus(http.StatusBadRequest)
+		}
+	}
+
+	err = u.Tokens.RevokeAccessToken(ctx.UserContext(), ctx.Locals("jti").(string), ctx.Locals("exp").(int64))
+	if err != nil {
+		status, response := responses.ErrorServers(err.Error())
+		return ctx.Status(status).JSON(response)
+	}
+
+	if len(req.RefreshToken) != 0 {
+		err = u.Tokens.RevokeRefreshToken(ctx.UserContext(), req.RefreshToken, userID)
+		if err != nil && !errors.Is(err, functions.ErrTokenInvalid) {
+			status, response := responses.ErrorServers(err.Error())
+			return ctx.Status(status).JSON(response)
+		}
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "User logged out successfully",
+	})
+}
+
+func validateProfile(email, name string) error {
+	lenEmail := len(email)
+	lenName := len(name)
+
+	if lenEmail == 0 || lenName == 0 {
+		return errors.New("email and name cannot be empty")
+	}
+
+	if !validate_email(email) {
+		return errors.New("email is not in a valid format")
+	}
+
+	if lenEmail < 5 {
+		return errors.New("email length must be at least 5 characters")
+	}
+
+	if lenName > 50 {
+		return errors.New("name length cannot exceed 50 characters")
+	}
+
+	return nil
+}
+
+func (u *User) GetMe(ctx *fiber.Ctx) error {
+	result, err := u.Database.GetUserById(ctx.UserContext(), ctx.Locals("user_id").(string))
+	if err != nil {
+		if errors.Is(err, functions.ErrNoRow) {
+			status, response := responses.ErrorNotFound("USER_NOT_FOUND")
+			return ctx.Status(status).JSON(response)
+		}
+
+		status, response := responses.ErrorServers(err.Error())
+		return ctx.Status(status).JSON(response)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "success",
+		"data":    u.convertUserToResponse(result),
+	})
+}
+
+func (u *User) UpdateMe(ctx *fiber.Ctx) error {
+	var req struct {
+		Email              *string `json:"email"`
+		Name               *string `json:"name"`
+		EmailNotifications *bool   `json:"emailNotifications"`
+	}
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.SendStatus(http.StatusBadRequest)
+	}
+
+	current, err := u.Database.GetUserById(ctx.UserContext(), ctx.Locals("user_id").(string))
+	if err != nil {
+		if errors.Is(err, functions.ErrNoRow) {
+			status, response := responses.ErrorNotFound("USER_NOT_FOUND")
+			return ctx.Status(status).JSON(response)
+		}
+
+		status, response := responses.ErrorServers(err.Error())
+		return ctx.Status(status).JSON(response)
+	}
+
+	// only the fields present in the body are changed
+	if req.Email != nil {
+		current.Email = *req.Email
+	}
+	if req.Name != nil {
+		current.Name = *req.Name
+	}
+	if req.EmailNotifications != nil {
+		current.EmailNotifications = *req.EmailNotifications
+	}
+
+	if err := validateProfile(current.Email, current.Name); err != nil {
+		status, response := responses.ErrorBadRequests(err.Error())
+		return ctx.Status(status).JSON(response)
+	}
+
+	result, err := u.Database.UpdateProfile(ctx.UserContext(), current)
+	if err != nil {
+		if err.Error() == "EXISTING_EMAIL" {
+			status, response := responses.ErrorConflict(err.Error())
+			return ctx.Status(status).JSON(response)
+		}
+
+		status, response := responses.ErrorServers(err.Error())
+		return ctx.Status(status).JSON(response)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "User updated successfully",
+		"data":    u.convertUserToResponse(result),
+	})
+}
+
+func (u *User) ChangePassword(ctx *fiber.Ctx) error {
+	var req struct {
+		OldPassword string `json:"oldPassword"`
+		NewPassword string `json:"newPassword"`
+	}
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.SendStatus(http.StatusBadRequest)
+	}
+
+	lenPassword := len(req.NewPassword)
+	if len(req.OldPassword) == 0 || lenPassword == 0 {
+		status, response := responses.ErrorBadRequests("oldPassword and newPassword are required")
+		return ctx.Status(status).JSON(response)
+	}
+
+	if lenPassword < 5 || lenPassword > 15 {
+		status, response := responses.ErrorBadRequests("password length must be between 5 and 15 characters")
+		return ctx.Status(status).JSON(response)
+	}
+
+	userIDClaim := ctx.Locals("user_id").(string)
+	userID, err := strconv.Atoi(userIDClaim)
+	if err != nil {
+		return ctx.SendStatus(http.StatusUnauthorized)
+	}
+
+	err = u.Database.ChangePassword(ctx.UserContext(), userIDClaim, req.OldPassword, req.NewPassword)
+	if err != nil {
+		if errors.Is(err, functions.ErrNoRow) {
+			status, response := responses.ErrorNotFound("USER_NOT_FOUND")
+			return ctx.Status(status).JSON(response)
+		}
+
+		if err.Error() == "INVALID_PASSWORD" {
+			status, response := responses.ErrorBadRequests(err.Error())
+			return ctx.Status(status).JSON(response)
+		}
+
+		status, response := responses.ErrorServers(err.Error())
+		return ctx.Status(status).JSON(response)
+	}
+
+	// sessions opened with the old password must not survive the change
+	if err := u.Tokens.RevokeAllRefreshTokens(ctx.UserContext(), userID); err != nil {
+		status, response := responses.ErrorServers(err.Error())
+		return ctx.Status(status).JSON(response)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Password changed successfully",
+	})
+}
+
+func (u *User) DeleteMe(ctx *fiber.Ctx) error {
+	imageUrls, err := u.Database.Delete(ctx.UserContext(), ctx.Locals("user_id").(string))
+	if err != nil {
+		if errors.Is(err, functions.ErrNoRow) {
+			status, response := responses.ErrorNotFound("USER_NOT_FOUND")
+			return ctx.Status(status).JSON(response)
+		}
+
+		status, response := responses.ErrorServers(err.Error())
+		return ctx.Status(status).JSON(response)
+	}
+
+	storage.RemoveUnused(ctx.UserContext(), u.Storage, u.CatDatabase.IsImageUsed, imageUrls)
+
+	err = u.Tokens.RevokeAccessToken(ctx.UserContext(), ctx.Locals("jti").(string), ctx.Locals("exp").(int64))
+	if err != nil {
+		status, response := responses.ErrorServers(err.Error())
+		return ctx.Status(status).JSON(response)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "User deleted successfully",
+	})
+}
+
+func (u *User) convertUserToResponse(usr models.User) fiber.Map {
+	return fiber.Map{
+		"id":                 usr.Id,
+		"email":              usr.Email,
+		"name":               usr.Name,
+		"createdAt":          usr.CreatedAt.Format(time.RFC3339),
+		"emailNotifications": usr.EmailNotifications,
+		"emailVerified":      usr.EmailVerifiedAt != nil,
+	}
+}
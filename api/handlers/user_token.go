@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"CatsSocial/api/responses"
+	"CatsSocial/db/functions"
+	"CatsSocial/db/models"
+	"CatsSocial/mailer"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type userTokenMailData struct {
+	Name      string
+	Link      string
+	Token     string
+	ExpiresIn string
+}
+
+// formatTTL writes a token lifetime the way people read it, e.g. "2 days" or "1 hour".
+func formatTTL(d time.Duration) string {
+	unit, size := "minute", time.Minute
+	switch {
+	case d >= 24*time.Hour && d%(24*time.Hour) == 0:
+		unit, size = "day", 24*time.Hour
+	case d >= time.Hour && d%time.Hour == 0:
+		unit, size = "hour", time.Hour
+	}
+
+	n := int(d / size)
+	if n == 1 {
+		return "1 " + unit
+	}
+
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+// sendTokenMail emails usr a token for template, linking to path of the web app when
+// the app URL is known.
+func (u *User) sendTokenMail(ctx context.Context, usr models.User, template, path, token string, ttl time.Duration) error {
+	data := userTokenMailData{
+		Name:      usr.Name,
+		Token:     token,
+		ExpiresIn: formatTTL(ttl),
+	}
+
+	if u.AppURL != "" {
+		data.Link = strings.TrimSuffix(u.AppURL, "/") + path + "?token=" + url.QueryEscape(token)
+	}
+
+	msg, err := mailer.Render(template, data)
+	if err != nil {
+		return err
+	}
+
+	msg.To = usr.Email
+
+	return u.Mailer.Send(ctx, msg)
+}
+
+// mailVerification issues a verification token and emails it in the background,
+// e.g. right after registering.
+func (u *User) mailVerification(userID string) {
+	if u.Mailer == nil {
+		return
+	}
+
+	err := goMail(func(ctx context.Context) {
+		token, usr, err := u.Database.CreateVerificationToken(ctx, userID)
+		if err == nil {
+			err = u.sendTokenMail(ctx, usr, "verify_email", "/verify-email", token, u.VerifyTokenTTL)
+		}
+		if err != nil {
+			log.Printf("failed email verification to user %s: %v", userID, err)
+		}
+	})
+	if err != nil {
+		// the user can ask for the email again later
+		log.Printf("failed email verification to user %s: %v", userID, err)
+	}
+}
+
+// SendVerification emails the user a new verification token, earlier tokens stop working.
+func (u *User) SendVerification(ctx *fiber.Ctx) error {
+	if u.Mailer == nil {
+		status, response := responses.ErrorServers("emails are disabled")
+		return ctx.Status(status).JSON(response)
+	}
+
+	token, usr, err := u.Database.CreateVerificationToken(ctx.UserContext(), ctx.Locals("user_id").(string))
+	if err != nil {
+		if errors.Is(err, functions.ErrNoRow) {
+			status, response := responses.ErrorNotFound("USER_NOT_FOUND")
+			return ctx.Status(status).JSON(response)
+		}
+
+		if errors.Is(err, functions.ErrEmailAlreadyVerified) {
+			status, response := responses.ErrorConflict(err.Error())
+			return ctx.Status(status).JSON(response)
+		}
+
+		status, response := responses.ErrorServers(err.Error())
+		return ctx.Status(status).JSON(response)
+	}
+
+	// the token is already stored, only the email goes out in the background
+	err = goMail(func(mailCtx context.Context) {
+		if err := u.sendTokenMail(mailCtx, usr, "verify_email", "/verify-email", token, u.VerifyTokenTTL); err != nil {
+			log.Printf("failed email verification to user %s: %v", usr.Id, err)
+		}
+	})
+	if err != nil {
+		status, response := responses.ErrorTooManyRequests(err.Error())
+		return ctx.Status(status).JSON(response)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Verification email sent",
+	})
+}
+
+// VerifyEmail marks the email a verification token was mailed to as verified.
+func (u *User) VerifyEmail(ctx *fiber.Ctx) error {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.SendStatus(http.StatusBadRequest)
+	}
+
+	if len(req.Token) == 0 {
+		status, response := responses.ErrorBadRequests("token is required")
+		return ctx.Status(status).JSON(response)
+	}
+
+	if err := u.Database.VerifyEmail(ctx.UserContext(), req.Token); err != nil {
+		if errors.Is(err, functions.ErrUserTokenInvalid) {
+			status, response := responses.ErrorBadRequests(err.Error())
+			return ctx.Status(status).JSON(response)
+		}
+
+		status, response := responses.ErrorServers(err.Error())
+		return ctx.Status(status).JSON(response)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Email verified successfully",
+	})
+}
+
+// RequestPasswordReset emails a reset token to the user with the given email. The
+// response is the same whether the email is registered or not, so that it cannot
+// be used to find out who has an account.
+func (u *User) RequestPasswordReset(ctx *fiber.Ctx) error {
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.SendStatus(http.StatusBadRequest)
+	}
+
+	if !validate_email(req.Email) {
+		status, response := responses.ErrorBadRequests("email is not in a valid format")
+		return ctx.Status(status).JSON(response)
+	}
+
+	if u.Mailer == nil {
+		status, response := responses.ErrorServers("emails are disabled")
+		return ctx.Status(status).JSON(response)
+	}
+
+	// the lookup and the email both happen in the background, a registered email
+	// takes no longer to answer than an unknown one. Unknown emails and emails
+	// whose last reset token is still valid get no email.
+	err := goMail(func(mailCtx context.Context) {
+		token, usr, err := u.Database.CreatePasswordResetToken(mailCtx, req.Email)
+		if errors.Is(err, functions.ErrNoRow) || errors.Is(err, functions.ErrUserTokenActive) {
+			return
+		}
+		if err == nil {
+			err = u.sendTokenMail(mailCtx, usr, "reset_password", "/reset-password", token, u.ResetTokenTTL)
+		}
+		if err != nil {
+			log.Printf("failed email password reset: %v", err)
+		}
+	})
+	if err != nil {
+		status, response := responses.ErrorTooManyRequests(err.Error())
+		return ctx.Status(status).JSON(response)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "If the email is registered, a password reset email was sent",
+	})
+}
+
+// ResetPassword sets a new password with a reset token and signs the user out of
+// every device.
+func (u *User) ResetPassword(ctx *fiber.Ctx) error {
+	var req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.SendStatus(http.StatusBadRequest)
+	}
+
+	if len(req.Token) == 0 || len(req.Password) == 0 {
+		status, response := responses.ErrorBadRequests("token and password are required")
+		return ctx.Status(status).JSON(response)
+	}
+
+	if lenPassword := len(req.Password); lenPassword < 5 || lenPassword > 15 {
+		status, response := responses.ErrorBadRequests("password length must be between 5 and 15 characters")
+		return ctx.Status(status).JSON(response)
+	}
+
+	userID, err := u.Database.ResetPassword(ctx.UserContext(), req.Token, req.Password)
+	if err != nil {
+		if errors.Is(err, functions.ErrUserTokenInvalid) {
+			status, response := responses.ErrorBadRequests(err.Error())
+			return ctx.Status(status).JSON(response)
+		}
+
+		status, response := responses.ErrorServers(err.Error())
+		return ctx.Status(status).JSON(response)
+	}
+
+	// sessions opened with the old password must not survive the reset
+	if err := u.Tokens.RevokeAllRefreshTokens(ctx.UserContext(), userID); err != nil {
+		status, response := responses.ErrorServers(err.Error())
+		return ctx.Status(status).JSON(response)
+	}
+
+	return ctx.Status(fiber.StatusOK).JSON(fiber.Map{
+		"message": "Password reset successfully",
+	})
+}
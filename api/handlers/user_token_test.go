@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatTTL(t *testing.T) {
+	tests := []struct {
+		ttl  time.Duration
+		want string
+	}{
+		{time.Minute, "1 minute"},
+		{30 * time.Minute, "30 minutes"},
+		{90 * time.Minute, "90 minutes"},
+		{time.Hour, "1 hour"},
+		{2 * time.Hour, "2 hours"},
+		{24 * time.Hour, "1 day"},
+		{48 * time.Hour, "2 days"},
+		{36 * time.Hour, "36 hours"},
+		{7 * 24 * time.Hour, "7 days"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := formatTTL(tt.ttl); got != tt.want {
+				t.Errorf("formatTTL(%v) = %q, want %q", tt.ttl, got, tt.want)
+			}
+		})
+	}
+}
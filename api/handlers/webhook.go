@@ -0,0 +1,232 @@
+package handlers
+
+import (
+	"CatsSocial/api/responses"
+	"CatsSocial/db/functions"
+	"CatsSocial/db/models"
+	"CatsSocial/webhook"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	validation "github.com/go-ozzo/ozzo-validation"
+	"github.com/go-ozzo/ozzo-validation/is"
+	"github.com/gofiber/fiber/v2"
+)
+
+type (
+	Webhook struct {
+		Database *functions.Webhook
+		// AllowPrivate lets webhooks point to private addresses, see configs.Config.
+		AllowPrivate bool
+	}
+
+	WebhookPayload struct {
+		Url    string   `json:"url"`
+		Events []string `json:"events"`
+	}
+)
+
+var webhookScheme = regexp.MustCompile(`^https?://`)
+
+func (app WebhookPayload) Validate() error {
+	return validation.ValidateStruct(&app,
+		// Url should be an http or https url.
+		validation.Field(&app.Url, validation.Required, validation.Length(1, 2048), is.URL, validation.Match(webhookScheme)),
+		// Events should be webhook events, an empty list subscribes to every event.
+		validation.Field(&app.Events, validation.Each(validation.In(
+			models.WebhookEventMatchRequested,
+			models.WebhookEventMatchReissued,
+			models.WebhookEventMatchApproved,
+			models.WebhookEventMatchRejected,
+			models.WebhookEventMatchWithdrawn,
+			models.WebhookEventMatchSuperseded,
+			models.WebhookEventMatchExpired,
+			models.WebhookEventCatCreated,
+			models.WebhookEventCatUpdated,
+			models.WebhookEventCatDeleted,
+			models.WebhookEventCatRestored,
+		))),
+	)
+}
+
+func (w *Webhook) handleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, fiber.ErrBadRequest):
+		status, response := responses.ErrorBadRequests("bad request")
+		return c.Status(status).JSON(response)
+	case errors.Is(err, fiber.ErrNotFound), errors.Is(err, functions.ErrNoRow):
+		status, response := responses.ErrorNotFound("not found")
+		return c.Status(status).JSON(response)
+	default:
+		validationErrors, ok := err.(validation.Errors)
+		if !ok {
+			status, response := responses.ErrorServer(err.Error())
+			return c.Status(status).JSON(response)
+		}
+
+		errMessages := []string{}
+		for key, ve := range validationErrors {
+			errMessages = append(errMessages, fmt.Sprintf(
+				"field %s: %s",
+				key,
+				ve.Error()))
+		}
+
+		status, response := responses.ErrorBadRequests(strings.Join(errMessages, ""))
+		return c.Status(status).JSON(response)
+	}
+}
+
+// Create registers a webhook for the notifications of the user. The response holds
+// the signing secret, it cannot be read again later.
+func (w *Webhook) Create(c *fiber.Ctx) error {
+	userIDClaim := c.Locals("user_id").(string)
+	userID, err := strconv.Atoi(userIDClaim)
+	if err != nil {
+		return c.SendStatus(http.StatusUnauthorized)
+	}
+
+	var payload WebhookPayload
+	if err := c.BodyParser(&payload); err != nil {
+		return w.handleError(c, fiber.ErrBadRequest)
+	}
+
+	if err := payload.Validate(); err != nil {
+		return w.handleError(c, err)
+	}
+
+	if err := webhook.CheckURL(c.UserContext(), payload.Url, w.AllowPrivate); err != nil {
+		return w.handleError(c, validation.Errors{"url": err})
+	}
+
+	created, err := w.Database.Create(c.UserContext(), models.Webhook{
+		UserId: userID,
+		Url:    payload.Url,
+		Events: payload.Events,
+	})
+	if err != nil {
+		return w.handleError(c, err)
+	}
+
+	return c.Status(http.StatusCreated).JSON(map[string]interface{}{
+		"message": "success",
+		"data":    created,
+	})
+}
+
+func (w *Webhook) Get(c *fiber.Ctx) error {
+	userIDClaim := c.Locals("user_id").(string)
+	userID, err := strconv.Atoi(userIDClaim)
+	if err != nil {
+		return c.SendStatus(http.StatusUnauthorized)
+	}
+
+	webhooks, err := w.Database.FindAll(c.UserContext(), userID)
+	if err != nil {
+		return w.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "success",
+		"data":    webhooks,
+	})
+}
+
+func (w *Webhook) Delete(c *fiber.Ctx) error {
+	userIDClaim := c.Locals("user_id").(string)
+	userID, err := strconv.Atoi(userIDClaim)
+	if err != nil {
+		return c.SendStatus(http.StatusUnauthorized)
+	}
+
+	webhookID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return w.handleError(c, fiber.ErrNotFound)
+	}
+
+	if err := w.Database.Delete(c.UserContext(), userID, webhookID); err != nil {
+		return w.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "success",
+	})
+}
+
+// Deliveries is the delivery log of a webhook, newest first.
+func (w *Webhook) Deliveries(c *fiber.Ctx) error {
+	userIDClaim := c.Locals("user_id").(string)
+	userID, err := strconv.Atoi(userIDClaim)
+	if err != nil {
+		return c.SendStatus(http.StatusUnauthorized)
+	}
+
+	webhookID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return w.handleError(c, fiber.ErrNotFound)
+	}
+
+	var filter struct {
+		Status string `json:"status"`
+		Limit  int    `json:"limit"`
+		Offset int    `json:"offset"`
+	}
+
+	if err := c.QueryParser(&filter); err != nil {
+		return w.handleError(c, fiber.ErrBadRequest)
+	}
+
+	err = validation.ValidateStruct(&filter,
+		validation.Field(&filter.Status, validation.In(models.WebhookDeliveryPending, models.WebhookDeliveryDelivered, models.WebhookDeliveryFailed)),
+		validation.Field(&filter.Limit, validation.Min(0)),
+		validation.Field(&filter.Offset, validation.Min(0)),
+	)
+	if err != nil {
+		return w.handleError(c, err)
+	}
+
+	if filter.Limit == 0 {
+		filter.Limit = 20
+	}
+
+	deliveries, err := w.Database.FindDeliveries(c.UserContext(), userID, webhookID, filter.Status, filter.Limit, filter.Offset)
+	if err != nil {
+		return w.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "success",
+		"data":    deliveries,
+	})
+}
+
+// Retry queues a failed delivery again.
+func (w *Webhook) Retry(c *fiber.Ctx) error {
+	userIDClaim := c.Locals("user_id").(string)
+	userID, err := strconv.Atoi(userIDClaim)
+	if err != nil {
+		return c.SendStatus(http.StatusUnauthorized)
+	}
+
+	webhookID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return w.handleError(c, fiber.ErrNotFound)
+	}
+
+	deliveryID, err := strconv.Atoi(c.Params("deliveryId"))
+	if err != nil {
+		return w.handleError(c, fiber.ErrNotFound)
+	}
+
+	if err := w.Database.Retry(c.UserContext(), userID, webhookID, deliveryID); err != nil {
+		return w.handleError(c, err)
+	}
+
+	return c.Status(http.StatusOK).JSON(map[string]interface{}{
+		"message": "success",
+	})
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"CatsSocial/db/functions"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RequireAdmin only lets administrators through, it must run after JWTAuth.
+func RequireAdmin(users *functions.User) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("user_id").(string)
+		if !ok {
+			return fiber.ErrUnauthorized
+		}
+
+		user, err := users.GetUserById(c.UserContext(), userID)
+		if err != nil {
+			return fiber.ErrUnauthorized
+		}
+
+		if !user.IsAdmin {
+			return fiber.ErrForbidden
+		}
+
+		return c.Next()
+	}
+}
@@ -1,34 +1,38 @@
 package middleware
 
 import (
-	"CatsSocial/configs"
+	"CatsSocial/auth"
+	"CatsSocial/db/functions"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
-	jwtware "github.com/gofiber/jwt/v2"
-	"github.com/golang-jwt/jwt/v4"
 )
 
-func JWTAuth() fiber.Handler {
-	config, _ := configs.LoadConfig()
+func JWTAuth(a *auth.Auth, tokens *functions.Token) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		header := c.Get(fiber.HeaderAuthorization)
 
-	return jwtware.New(jwtware.Config{
-		SigningKey: []byte(config.JWTSecret),
-		Filter: func(c *fiber.Ctx) bool {
-			return false
-		},
-		ErrorHandler: func(c *fiber.Ctx, err error) error {
-			if err != nil {
-				return fiber.ErrUnauthorized
-			}
-			return c.Next()
-		},
-		SuccessHandler: func(c *fiber.Ctx) error {
-			token := c.Locals("user").(*jwt.Token)
-			claims := token.Claims.(jwt.MapClaims)
+		tokenString, found := strings.CutPrefix(header, "Bearer ")
+		if !found || tokenString == "" {
+			return fiber.ErrUnauthorized
+		}
 
-			userID := claims["user_id"].(string)
-			c.Locals("user_id", userID)
-			return c.Next()
-		},
-	})
+		claims, err := a.Verify(tokenString)
+		if err != nil {
+			return fiber.ErrUnauthorized
+		}
+
+		revoked, err := tokens.IsAccessTokenRevoked(c.UserContext(), claims.Id)
+		if err != nil {
+			return fiber.ErrInternalServerError
+		}
+		if revoked {
+			return fiber.ErrUnauthorized
+		}
+
+		c.Locals("user_id", claims.UserId)
+		c.Locals("jti", claims.Id)
+		c.Locals("exp", claims.ExpiresAt)
+		return c.Next()
+	}
 }
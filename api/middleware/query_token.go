@@ -0,0 +1,16 @@
+package middleware
+
+import "github.com/gofiber/fiber/v2"
+
+// TokenFromQuery lets clients that cannot set headers, such as the browser
+// EventSource, pass their access token as the access_token query param. It must
+// run before JWTAuth.
+func TokenFromQuery() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if token := c.Query("access_token"); token != "" && c.Get(fiber.HeaderAuthorization) == "" {
+			c.Request().Header.Set(fiber.HeaderAuthorization, "Bearer "+token)
+		}
+
+		return c.Next()
+	}
+}
@@ -7,6 +7,20 @@ func ErrorBadRequests(m string) (int, map[string]interface{}) {
 	}
 }
 
+func ErrorUnauthorized(m string) (int, map[string]interface{}) {
+	return 401, map[string]interface{}{
+		"status":  "Error",
+		"message": m,
+	}
+}
+
+func ErrorForbidden(m string) (int, map[string]interface{}) {
+	return 403, map[string]interface{}{
+		"status":  "Error",
+		"message": m,
+	}
+}
+
 func ErrorNotFound(m string) (int, map[string]interface{}) {
 	return 404, map[string]interface{}{
 		"status":  "Error",
@@ -21,6 +35,13 @@ func ErrorConflict(m string) (int, map[string]interface{}) {
 	}
 }
 
+func ErrorTooManyRequests(m string) (int, map[string]interface{}) {
+	return 429, map[string]interface{}{
+		"status":  "Error",
+		"message": m,
+	}
+}
+
 func ErrorServer(m string) (int, map[string]interface{}) {
 	return 500, map[string]interface{}{
 		"status":  "Error",
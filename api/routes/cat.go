@@ -2,15 +2,17 @@ package routes
 
 import (
 	"CatsSocial/api/handlers"
-	"CatsSocial/api/middleware"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-func CatRoutes(app *fiber.App, h handlers.Cat) {
-	g := app.Group("/v1/cat").Use(middleware.JWTAuth())
+func CatRoutes(app *fiber.App, h handlers.Cat, auth fiber.Handler, admin fiber.Handler) {
+	g := app.Group("/v1/cat").Use(auth)
 	g.Get("", h.GetCats)
+	g.Get("/races", h.GetRaces)
 	g.Post("", h.AddCat)
 	g.Put("/:id", h.UpdateCat)
 	g.Delete("/:id", h.DeleteCat)
+	g.Get("/:id/recommendations", h.GetRecommendations)
+	g.Post("/:id/restore", admin, h.RestoreCat)
 }
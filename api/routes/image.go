@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"CatsSocial/api/handlers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func ImageRoutes(app *fiber.App, h handlers.Image, auth fiber.Handler) {
+	g := app.Group("/v1/image").Use(auth)
+	g.Post("", h.Upload)
+}
@@ -2,7 +2,10 @@ package routes
 
 import (
 	"CatsSocial/api/handlers"
+	"CatsSocial/api/middleware"
 	"CatsSocial/db/functions"
+	"CatsSocial/match"
+	"CatsSocial/storage"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -12,24 +15,86 @@ func RouteRegister(app *fiber.App, deps handlers.Dependencies) {
 		return c.SendString("pong")
 	})
 
+	tokenDatabase := functions.NewToken(deps.DbPool, deps.Cfg)
+	auth := middleware.JWTAuth(deps.Auth, tokenDatabase)
+
+	admin := middleware.RequireAdmin(functions.NewUser(deps.DbPool, deps.Cfg))
+
 	userHandler := handlers.User{
-		Database: functions.NewUser(deps.DbPool, deps.Cfg),
+		Database:    functions.NewUser(deps.DbPool, deps.Cfg),
+		Tokens:      tokenDatabase,
+		Auth:        deps.Auth,
+		CatDatabase: functions.NewCatFn(deps.DbPool),
+		Storage:     deps.Storage,
+		Mailer:      deps.Mailer,
+		AppURL:      deps.Cfg.AppURL,
+
+		VerifyTokenTTL: deps.Cfg.EmailVerifyTokenTTL,
+		ResetTokenTTL:  deps.Cfg.PasswordResetTokenTTL,
+	}
+
+	UserRoutes(app, userHandler, auth)
+
+	// files of the local storage are served by the app itself
+	if local, ok := deps.Storage.(*storage.Local); ok {
+		app.Static("/uploads", local.Dir())
 	}
 
-	UserRoutes(app, userHandler)
+	imageHandler := handlers.Image{
+		Storage: deps.Storage,
+		MaxSize: deps.Cfg.ImageMaxSize,
+	}
+
+	ImageRoutes(app, imageHandler, auth)
 
 	catHandler := handlers.Cat{
 		Database:     functions.NewCatFn(deps.DbPool),
 		UserDatabase: functions.NewUser(deps.DbPool, deps.Cfg),
+		Races:        functions.NewRace(deps.DbPool),
+		Storage:      deps.Storage,
+	}
+
+	CatRoutes(app, catHandler, auth, admin)
+
+	moderationDatabase := functions.NewModeration(deps.DbPool)
+
+	moderationHandler := handlers.Moderation{
+		Database: moderationDatabase,
 	}
 
-	CatRoutes(app, catHandler)
+	ModerationRoutes(app, moderationHandler, auth, admin)
+
+	matchDatabase := functions.NewMatch(deps.DbPool, deps.Cfg)
 
 	matchHandler := handlers.MatchHandler{
-		Match:        *functions.NewMatch(deps.DbPool),
-		CatDatabase:  functions.NewCatFn(deps.DbPool),
-		UserDatabase: functions.NewUser(deps.DbPool, deps.Cfg),
+		Match:         *matchDatabase,
+		CatDatabase:   functions.NewCatFn(deps.DbPool),
+		UserDatabase:  functions.NewUser(deps.DbPool, deps.Cfg),
+		Rules:         match.New(deps.Cfg, matchDatabase, moderationDatabase),
+		ApprovalRules: match.NewApproval(deps.Cfg, moderationDatabase),
+		Mailer:        deps.Mailer,
+
+		RequireVerifiedEmail: deps.Cfg.RequireVerifiedEmail,
+	}
+
+	MatchRoutes(app, matchHandler, auth)
+
+	notificationHandler := handlers.NotificationHandler{
+		Database: functions.NewNotification(deps.DbPool),
+	}
+
+	NotificationRoutes(app, notificationHandler, auth)
+
+	streamHandler := handlers.Stream{
+		Hub: deps.Hub,
+	}
+
+	StreamRoutes(app, streamHandler, auth)
+
+	webhookHandler := handlers.Webhook{
+		Database:     functions.NewWebhook(deps.DbPool),
+		AllowPrivate: deps.Cfg.WebhookAllowPrivate,
 	}
 
-	MatchRoutes(app, matchHandler)
+	WebhookRoutes(app, webhookHandler, auth)
 }
@@ -2,17 +2,19 @@ package routes
 
 import (
 	"CatsSocial/api/handlers"
-	"CatsSocial/api/middleware"
 
 	"github.com/gofiber/fiber/v2"
 )
 
-func MatchRoutes(app *fiber.App, h handlers.MatchHandler) {
-	g := app.Group("/v1/cat/match").Use(middleware.JWTAuth())
+func MatchRoutes(app *fiber.App, h handlers.MatchHandler, auth fiber.Handler) {
+	g := app.Group("/v1/cat/match").Use(auth)
 
 	g.Post("", h.Create)
 	g.Get("", h.Get)
+	g.Post("/check", h.Check)
 	g.Post("/approve", h.Approve)
 	g.Post("/reject", h.Reject)
 	g.Delete("/:id", h.Delete)
+	g.Post("/:id/reissue", h.Reissue)
+	g.Get("/:id/history", h.History)
 }
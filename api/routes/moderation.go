@@ -0,0 +1,17 @@
+package routes
+
+import (
+	"CatsSocial/api/handlers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func ModerationRoutes(app *fiber.App, h handlers.Moderation, auth fiber.Handler, admin fiber.Handler) {
+	app.Post("/v1/user/:id/block", auth, h.Block)
+	app.Delete("/v1/user/:id/block", auth, h.Unblock)
+
+	g := app.Group("/v1/report").Use(auth)
+	g.Post("", h.Report)
+	g.Get("", admin, h.GetReports)
+	g.Post("/:id/resolve", admin, h.ResolveReport)
+}
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	"CatsSocial/api/handlers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func NotificationRoutes(app *fiber.App, h handlers.NotificationHandler, auth fiber.Handler) {
+	g := app.Group("/v1/notification").Use(auth)
+	g.Get("", h.Get)
+	g.Post("/read", h.Read)
+}
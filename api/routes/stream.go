@@ -0,0 +1,12 @@
+package routes
+
+import (
+	"CatsSocial/api/handlers"
+	"CatsSocial/api/middleware"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func StreamRoutes(app *fiber.App, h handlers.Stream, auth fiber.Handler) {
+	app.Get("/v1/stream", middleware.TokenFromQuery(), auth, h.Get)
+}
@@ -6,8 +6,20 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-func UserRoutes(app *fiber.App, userHandler handlers.User) {
+func UserRoutes(app *fiber.App, userHandler handlers.User, auth fiber.Handler) {
 	g := app.Group("/v1/user")
 	g.Post("/register", userHandler.Register)
 	g.Post("/login", userHandler.Login)
+	g.Post("/refresh", userHandler.Refresh)
+	g.Post("/logout", auth, userHandler.Logout)
+	g.Post("/verify", userHandler.VerifyEmail)
+	g.Post("/password/reset", userHandler.RequestPasswordReset)
+	g.Post("/password/reset/confirm", userHandler.ResetPassword)
+
+	me := g.Group("/me", auth)
+	me.Get("", userHandler.GetMe)
+	me.Patch("", userHandler.UpdateMe)
+	me.Post("/password", userHandler.ChangePassword)
+	me.Post("/verify", userHandler.SendVerification)
+	me.Delete("", userHandler.DeleteMe)
 }
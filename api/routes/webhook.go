@@ -0,0 +1,16 @@
+package routes
+
+import (
+	"CatsSocial/api/handlers"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func WebhookRoutes(app *fiber.App, h handlers.Webhook, auth fiber.Handler) {
+	g := app.Group("/v1/webhook").Use(auth)
+	g.Post("", h.Create)
+	g.Get("", h.Get)
+	g.Delete("/:id", h.Delete)
+	g.Get("/:id/deliveries", h.Deliveries)
+	g.Post("/:id/deliveries/:deliveryId/retry", h.Retry)
+}
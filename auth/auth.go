@@ -0,0 +1,168 @@
+package auth
+
+import (
+	"CatsSocial/configs"
+	"bytes"
+	"crypto"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/google/uuid"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+type (
+	// Auth issues and verifies access tokens. It is built once from the config
+	// and shared by the handlers and the middleware.
+	Auth struct {
+		method     jwt.SigningMethod
+		kid        string
+		signingKey interface{}
+		// verifyKeys holds the current key and the retired ones, indexed by kid.
+		verifyKeys map[string]interface{}
+		ttl        time.Duration
+	}
+
+	Claims struct {
+		Username string `json:"username"`
+		UserId   string `json:"user_id"`
+		jwt.StandardClaims
+	}
+)
+
+func New(config configs.Config) (*Auth, error) {
+	a := &Auth{
+		kid:        config.JWTKeyId,
+		verifyKeys: map[string]interface{}{},
+		ttl:        config.AccessTokenTTL,
+	}
+
+	switch config.JWTAlgorithm {
+	case "HS256":
+		if config.JWTSecret == "" {
+			return nil, errors.New("JWT_SECRET is required for HS256")
+		}
+
+		a.method = jwt.SigningMethodHS256
+		a.signingKey = []byte(config.JWTSecret)
+		a.verifyKeys[a.kid] = a.signingKey
+	case "RS256":
+		pem, err := os.ReadFile(config.JWTPrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed read jwt private key: %v", err)
+		}
+
+		key, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("failed parse jwt private key: %v", err)
+		}
+
+		a.method = jwt.SigningMethodRS256
+		a.signingKey = key
+		a.verifyKeys[a.kid] = &key.PublicKey
+	case "EdDSA":
+		pem, err := os.ReadFile(config.JWTPrivateKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed read jwt private key: %v", err)
+		}
+
+		key, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("failed parse jwt private key: %v", err)
+		}
+
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("jwt private key is not an ed25519 key")
+		}
+
+		a.method = jwt.SigningMethodEdDSA
+		a.signingKey = key
+		a.verifyKeys[a.kid] = signer.Public()
+	default:
+		return nil, fmt.Errorf("unsupported jwt algorithm %q", config.JWTAlgorithm)
+	}
+
+	for kid, path := range config.JWTVerifyKeys {
+		key, err := a.loadVerifyKey(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed load jwt verify key %s: %v", kid, err)
+		}
+
+		// never let a retired key shadow the current one
+		if _, exists := a.verifyKeys[kid]; !exists {
+			a.verifyKeys[kid] = key
+		}
+	}
+
+	return a, nil
+}
+
+func (a *Auth) loadVerifyKey(path string) (interface{}, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch a.method {
+	case jwt.SigningMethodRS256:
+		return jwt.ParseRSAPublicKeyFromPEM(content)
+	case jwt.SigningMethodEdDSA:
+		return jwt.ParseEdPublicKeyFromPEM(content)
+	default:
+		// secret files usually end with a newline, it is not part of the secret
+		return bytes.TrimSpace(content), nil
+	}
+}
+
+// Issue generates a signed access token for the given user.
+func (a *Auth) Issue(username, userId string) (string, error) {
+	now := time.Now()
+
+	token := jwt.NewWithClaims(a.method, Claims{
+		Username: username,
+		UserId:   userId,
+		StandardClaims: jwt.StandardClaims{
+			// the jti identifies the token so that it can be revoked on logout
+			Id:        uuid.NewString(),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(a.ttl).Unix(),
+		},
+	})
+	token.Header["kid"] = a.kid
+
+	return token.SignedString(a.signingKey)
+}
+
+// Verify checks the signature and the expiry of a token and returns its claims.
+func (a *Auth) Verify(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		// only accept the configured algorithm, never whatever the token asks for
+		if t.Method.Alg() != a.method.Alg() {
+			return nil, ErrInvalidToken
+		}
+
+		kid, _ := t.Header["kid"].(string)
+		key, ok := a.verifyKeys[kid]
+		if !ok {
+			return nil, ErrInvalidToken
+		}
+
+		return key, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, ErrInvalidToken
+	}
+
+	if claims.UserId == "" || claims.Id == "" || claims.ExpiresAt == 0 {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"CatsSocial/configs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRetiredHS256KeyFileWithNewline(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"no newline", "old-secret"},
+		{"trailing newline", "old-secret\n"},
+		{"windows newline", "old-secret\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// tokens issued before the rotation were signed with the old secret
+			old, err := New(configs.Config{JWTAlgorithm: "HS256", JWTSecret: "old-secret", JWTKeyId: "old", AccessTokenTTL: time.Minute})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			token, err := old.Issue("budi", "1")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			path := filepath.Join(t.TempDir(), "old.key")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			rotated, err := New(configs.Config{
+				JWTAlgorithm:   "HS256",
+				JWTSecret:      "new-secret",
+				JWTKeyId:       "new",
+				JWTVerifyKeys:  map[string]string{"old": path},
+				AccessTokenTTL: time.Minute,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			claims, err := rotated.Verify(token)
+			if err != nil {
+				t.Fatalf("Verify() of a token signed with the retired key = %v", err)
+			}
+			if claims.UserId != "1" {
+				t.Errorf("Verify() user id = %s, want 1", claims.UserId)
+			}
+		})
+	}
+}
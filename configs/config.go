@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
@@ -18,6 +20,80 @@ type Config struct {
 
 	JWTSecret  string
 	BcryptSalt int
+
+	// JWTAlgorithm is one of HS256, RS256 or EdDSA. The asymmetric algorithms sign
+	// with the PEM key in JWTPrivateKeyFile.
+	JWTAlgorithm      string
+	JWTPrivateKeyFile string
+	// JWTKeyId is written as the kid header of every issued token.
+	JWTKeyId string
+	// JWTVerifyKeys maps the kid of retired keys to a file holding the public key
+	// (or the secret for HS256) so that tokens signed before a rotation stay valid.
+	JWTVerifyKeys map[string]string
+
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+
+	// StorageDriver selects where uploaded images go, "local" or "s3".
+	StorageDriver    string
+	StorageLocalDir  string
+	StoragePublicURL string
+	ImageMaxSize     int64
+
+	S3Endpoint        string
+	S3Region          string
+	S3Bucket          string
+	S3AccessKeyId     string
+	S3SecretAccessKey string
+	S3UsePathStyle    bool
+
+	// MatchRequestTTL is how long a match request stays pending before it expires,
+	// MatchExpiryInterval is how often the server looks for expired requests.
+	MatchRequestTTL     time.Duration
+	MatchExpiryInterval time.Duration
+
+	// CatPurgeAfter is how long a deleted cat can still be restored before it is
+	// removed for good along with its matches and images, CatPurgeInterval is how
+	// often the server looks for such cats.
+	CatPurgeAfter    time.Duration
+	CatPurgeInterval time.Duration
+
+	// Optional match rules, a zero value disables the rule. MatchMaxAgeGap is in
+	// months and MatchBlockList holds user id pairs that can never match.
+	MatchSameRaceOnly     bool
+	MatchMaxAgeGap        int
+	MatchMaxPendingPerCat int
+	MatchBlockList        [][2]int
+
+	// WebhookDeliveryInterval is how often due webhook deliveries are sent. A failed
+	// attempt is retried after WebhookRetryBase, doubled on every further failure,
+	// until WebhookMaxAttempts attempts were made.
+	WebhookDeliveryInterval time.Duration
+	WebhookTimeout          time.Duration
+	WebhookRetryBase        time.Duration
+	WebhookMaxAttempts      int
+	// WebhookAllowPrivate lets webhooks point to loopback and private addresses,
+	// only meant for tests and local setups.
+	WebhookAllowPrivate bool
+
+	// MailDriver selects how emails go out, "log" (default), "file", "smtp" or
+	// "none" to turn them off. The file driver writes them to MailFileDir.
+	MailDriver   string
+	MailFrom     string
+	MailFileDir  string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+
+	// AppURL is the base URL of the web app, the links mailed to users point to it.
+	AppURL string
+
+	EmailVerifyTokenTTL   time.Duration
+	PasswordResetTokenTTL time.Duration
+	// RequireVerifiedEmail stops users from sending match requests until they
+	// verified their email.
+	RequireVerifiedEmail bool
 }
 
 func LoadConfig() (Config, error) {
@@ -32,6 +108,32 @@ func LoadConfig() (Config, error) {
 		ENV:     os.Getenv("ENV"),
 
 		JWTSecret: os.Getenv("JWT_SECRET"),
+
+		JWTAlgorithm:      os.Getenv("JWT_ALGORITHM"),
+		JWTPrivateKeyFile: os.Getenv("JWT_PRIVATE_KEY_FILE"),
+		JWTKeyId:          os.Getenv("JWT_KEY_ID"),
+		JWTVerifyKeys:     map[string]string{},
+
+		StorageDriver:    os.Getenv("STORAGE_DRIVER"),
+		StorageLocalDir:  os.Getenv("STORAGE_LOCAL_DIR"),
+		StoragePublicURL: os.Getenv("STORAGE_PUBLIC_URL"),
+
+		S3Endpoint:        os.Getenv("S3_ENDPOINT"),
+		S3Region:          os.Getenv("S3_REGION"),
+		S3Bucket:          os.Getenv("S3_BUCKET"),
+		S3AccessKeyId:     os.Getenv("S3_ACCESS_KEY_ID"),
+		S3SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+		S3UsePathStyle:    os.Getenv("S3_USE_PATH_STYLE") != "false",
+
+		MailDriver:   os.Getenv("MAIL_DRIVER"),
+		MailFrom:     os.Getenv("MAIL_FROM"),
+		MailFileDir:  os.Getenv("MAIL_FILE_DIR"),
+		SMTPHost:     os.Getenv("SMTP_HOST"),
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+
+		AppURL:               os.Getenv("APP_URL"),
+		RequireVerifiedEmail: os.Getenv("REQUIRE_VERIFIED_EMAIL") == "true",
 	}
 
 	salt, err := strconv.Atoi(os.Getenv("BCRYPT_SALT"))
@@ -45,5 +147,186 @@ func LoadConfig() (Config, error) {
 
 	config.BcryptSalt = salt
 
+	if config.JWTAlgorithm == "" {
+		config.JWTAlgorithm = "HS256"
+	}
+
+	if config.JWTKeyId == "" {
+		config.JWTKeyId = "default"
+	}
+
+	// JWT_VERIFY_KEYS looks like "kid1=/path/key1.pem,kid2=/path/key2.pem"
+	if keys := os.Getenv("JWT_VERIFY_KEYS"); keys != "" {
+		for _, pair := range strings.Split(keys, ",") {
+			kid, path, found := strings.Cut(strings.TrimSpace(pair), "=")
+			if !found || kid == "" || path == "" {
+				return Config{}, fmt.Errorf("failed to parse JWT_VERIFY_KEYS entry %q", pair)
+			}
+			config.JWTVerifyKeys[kid] = path
+		}
+	}
+
+	config.AccessTokenTTL, err = durationEnv("ACCESS_TOKEN_TTL", 20*time.Minute)
+	if err != nil {
+		return Config{}, err
+	}
+
+	config.RefreshTokenTTL, err = durationEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+	if err != nil {
+		return Config{}, err
+	}
+
+	config.EmailVerifyTokenTTL, err = durationEnv("EMAIL_VERIFY_TOKEN_TTL", 48*time.Hour)
+	if err != nil {
+		return Config{}, err
+	}
+
+	config.PasswordResetTokenTTL, err = durationEnv("PASSWORD_RESET_TOKEN_TTL", time.Hour)
+	if err != nil {
+		return Config{}, err
+	}
+
+	config.MatchRequestTTL, err = durationEnv("MATCH_REQUEST_TTL", 7*24*time.Hour)
+	if err != nil {
+		return Config{}, err
+	}
+
+	config.MatchExpiryInterval, err = durationEnv("MATCH_EXPIRY_INTERVAL", time.Minute)
+	if err != nil {
+		return Config{}, err
+	}
+
+	config.CatPurgeAfter, err = durationEnv("CAT_PURGE_AFTER", 30*24*time.Hour)
+	if err != nil {
+		return Config{}, err
+	}
+
+	config.CatPurgeInterval, err = durationEnv("CAT_PURGE_INTERVAL", time.Hour)
+	if err != nil {
+		return Config{}, err
+	}
+
+	config.MatchSameRaceOnly = os.Getenv("MATCH_SAME_RACE_ONLY") == "true"
+
+	config.MatchMaxAgeGap, err = intEnv("MATCH_MAX_AGE_GAP")
+	if err != nil {
+		return Config{}, err
+	}
+
+	config.MatchMaxPendingPerCat, err = intEnv("MATCH_MAX_PENDING_PER_CAT")
+	if err != nil {
+		return Config{}, err
+	}
+
+	// MATCH_BLOCK_LIST looks like "1:2,3:4", each pair of user ids blocks both ways
+	if pairs := os.Getenv("MATCH_BLOCK_LIST"); pairs != "" {
+		for _, pair := range strings.Split(pairs, ",") {
+			left, right, found := strings.Cut(strings.TrimSpace(pair), ":")
+			userId, errLeft := strconv.Atoi(left)
+			otherUserId, errRight := strconv.Atoi(right)
+			if !found || errLeft != nil || errRight != nil {
+				return Config{}, fmt.Errorf("failed to parse MATCH_BLOCK_LIST entry %q", pair)
+			}
+			config.MatchBlockList = append(config.MatchBlockList, [2]int{userId, otherUserId})
+		}
+	}
+
+	config.WebhookDeliveryInterval, err = durationEnv("WEBHOOK_DELIVERY_INTERVAL", 5*time.Second)
+	if err != nil {
+		return Config{}, err
+	}
+
+	config.WebhookTimeout, err = durationEnv("WEBHOOK_TIMEOUT", 10*time.Second)
+	if err != nil {
+		return Config{}, err
+	}
+
+	config.WebhookRetryBase, err = durationEnv("WEBHOOK_RETRY_BASE", 30*time.Second)
+	if err != nil {
+		return Config{}, err
+	}
+
+	config.WebhookMaxAttempts, err = intEnv("WEBHOOK_MAX_ATTEMPTS")
+	if err != nil {
+		return Config{}, err
+	}
+
+	if config.WebhookMaxAttempts <= 0 {
+		config.WebhookMaxAttempts = 8
+	}
+
+	config.WebhookAllowPrivate = os.Getenv("WEBHOOK_ALLOW_PRIVATE") == "true"
+
+	config.SMTPPort, err = intEnv("SMTP_PORT")
+	if err != nil {
+		return Config{}, err
+	}
+
+	if config.SMTPPort == 0 {
+		config.SMTPPort = 587
+	}
+
+	if config.MailDriver == "" {
+		config.MailDriver = "log"
+	}
+
+	if config.MailFrom == "" {
+		config.MailFrom = "Cats Social <no-reply@localhost>"
+	}
+
+	if config.MailFileDir == "" {
+		config.MailFileDir = "./mails"
+	}
+
+	if config.StorageDriver == "" {
+		config.StorageDriver = "local"
+	}
+
+	if config.StorageLocalDir == "" {
+		config.StorageLocalDir = "./uploads"
+	}
+
+	if config.S3Region == "" {
+		config.S3Region = "us-east-1"
+	}
+
+	config.ImageMaxSize = 2 * 1024 * 1024
+	if size := os.Getenv("IMAGE_MAX_SIZE"); size != "" {
+		config.ImageMaxSize, err = strconv.ParseInt(size, 10, 64)
+		if err != nil {
+			return Config{}, fmt.Errorf("failed to parse IMAGE_MAX_SIZE %v", err)
+		}
+	}
+
 	return config, nil
 }
+
+// durationEnv reads a duration such as "20m" or "720h", falling back to def when unset.
+func durationEnv(key string, def time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s %v", key, err)
+	}
+
+	return d, nil
+}
+
+// intEnv reads an integer, falling back to 0 when unset.
+func intEnv(key string) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s %v", key, err)
+	}
+
+	return n, nil
+}
@@ -5,7 +5,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"strings"
+	"strconv"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -21,63 +22,59 @@ func NewCatFn(dbPool *pgxpool.Pool) *Cat {
 	}
 }
 
-// FilterGetCats struct {
-// 	Id                 string `json:"id"`
-// 	Limit              int    `json:"limit"`
-// 	Offset             int    `json:"offset"`
-// 	Race               string `json:"Race"`
-// 	Sex                string `json:"sex"`
-// 	HasMatched         bool   `json:"hasMatched"`
-// 	AgeInMonthOperator string `json:"ageInMonthOperator"`
-// 	AgeInMonthValue    int    `json:"ageInMonthValue"`
-// 	Owned              bool   `json:"owned"`
-// 	Search             string `json:"search"`
-// }
-
-func (p *Cat) constructWhereQuery(ctx context.Context, filter models.FilterGetCats, userID int) string {
-	whereSQL := []string{}
+func (p *Cat) constructWhereQuery(filter models.FilterGetCats, userID int) *queryBuilder {
+	q := &queryBuilder{}
+
+	// soft deleted cats only live on in match history
+	q.Where("deleted_at IS NULL")
+
 	if filter.Owned {
-		whereSQL = append(whereSQL, " user_id = "+fmt.Sprintf("%d", userID))
+		q.Where("user_id = " + q.Arg(userID))
+	}
+
+	// users never see the cats of the users they blocked
+	if userID != 0 {
+		q.Where("user_id NOT IN (SELECT blocked_id FROM user_blocks WHERE blocker_id = " + q.Arg(userID) + ")")
 	}
 
 	if filter.Id != "" {
-		whereSQL = append(whereSQL, " id = '"+filter.Id+"'")
+		// a non numeric id can never match a cat
+		id, err := strconv.Atoi(filter.Id)
+		if err != nil {
+			q.Where("FALSE")
+		} else {
+			q.Where("id = " + q.Arg(id))
+		}
 	}
 
 	if filter.Race != "" {
-		whereSQL = append(whereSQL, " race = '"+filter.Race+"'")
+		q.Where("race = " + q.Arg(filter.Race))
 	}
 
 	if filter.Sex != "" {
-		whereSQL = append(whereSQL, " sex = '"+filter.Sex+"'")
+		q.Where("sex = " + q.Arg(filter.Sex))
 	}
 
-	if filter.HasMatched {
-		whereSQL = append(whereSQL, " hasMatched = '"+"1"+"'")
+	if filter.HasMatched != nil {
+		q.Where("has_matched = " + q.Arg(*filter.HasMatched))
 	}
-	// else {
-	// 	whereSQL = append(whereSQL, " hasMatched = '"+"0"+"'")
-	// }
 
-	if filter.AgeInMonthOperator != "" {
-		if filter.AgeInMonthOperator == ">" {
-			whereSQL = append(whereSQL, " age_in_month > "+fmt.Sprintf("%d", filter.AgeInMonthValue))
-		} else if filter.AgeInMonthOperator == "<" {
-			whereSQL = append(whereSQL, " age_in_month < "+fmt.Sprintf("%d", filter.AgeInMonthValue))
-		} else {
-			whereSQL = append(whereSQL, " age_in_month = "+fmt.Sprintf("%d", filter.AgeInMonthValue))
-		}
+	switch filter.AgeInMonthOperator {
+	case ">", "<", "=":
+		q.Where("age_in_month " + filter.AgeInMonthOperator + " " + q.Arg(filter.AgeInMonthValue))
 	}
 
 	if filter.Search != "" {
-		whereSQL = append(whereSQL, " name ILIKE '%"+filter.Search+"%'")
-	}
-
-	if len(whereSQL) > 0 {
-		return " WHERE " + strings.Join(whereSQL, " AND ")
+		search := q.Arg(filter.Search)
+		if filter.SearchMode == models.SearchModeFullText {
+			// name % search is the trigram similarity operator, it catches typos in the name
+			q.Where("(search_vector @@ websearch_to_tsquery('english', " + search + ") OR name % " + search + ")")
+		} else {
+			q.Where("name ILIKE '%' || " + search + " || '%'")
+		}
 	}
 
-	return ""
+	return q
 }
 
 func (p *Cat) FindAll(ctx context.Context, filter models.FilterGetCats, userID int) ([]models.Cat, error) {
@@ -88,21 +85,48 @@ func (p *Cat) FindAll(ctx context.Context, filter models.FilterGetCats, userID i
 
 	defer conn.Release()
 
-	sql := `SELECT id, user_id, name, race, sex, age_in_month, description, image_urls, has_matched, created_at FROM cats`
+	sql := `SELECT id, user_id, name, race, sex, age_in_month, description, image_urls, has_matched, created_at`
+
+	q := p.constructWhereQuery(filter, userID)
 
-	sql += p.constructWhereQuery(ctx, filter, userID)
+	fullText := filter.Search != "" && filter.SearchMode == models.SearchModeFullText
 
-	sql += " ORDER BY " + "created_at" + " " + "DESC"
+	orderBy := " ORDER BY created_at DESC, id DESC"
 
-	// if filter.Limit > 0 {
-	// 	sql += " LIMIT " + fmt.Sprintf("%d", filter.Limit)
-	// }
+	if fullText {
+		search := q.Arg(filter.Search)
+		tsQuery := "websearch_to_tsquery('english', " + search + ")"
+		rank := "(ts_rank(search_vector, " + tsQuery + ") + similarity(name, " + search + "))"
+		headline := "'StartSel=<mark>, StopSel=</mark>, MaxFragments=2, HighlightAll=false'"
 
-	// if filter.Offset >= 0 {
-	// 	sql += " OFFSET " + fmt.Sprintf("%d", filter.Offset)
-	// }
+		sql += ", " + rank +
+			", ts_headline('english', name, " + tsQuery + ", " + headline + ")" +
+			", ts_headline('english', COALESCE(description, ''), " + tsQuery + ", " + headline + ")"
 
-	rows, err := conn.Query(ctx, sql)
+		orderBy = " ORDER BY " + rank + " DESC, created_at DESC, id DESC"
+	}
+
+	sql += " FROM cats"
+
+	// keyset pagination continues right after the last cat of the previous page,
+	// relevance ordering cannot be resumed from a cursor so offset is used instead
+	if filter.Cursor != nil && !fullText {
+		q.Where("(created_at, id) < (" + q.Arg(filter.Cursor.CreatedAt) + ", " + q.Arg(filter.Cursor.Id) + ")")
+	}
+
+	sql += q.WhereSQL()
+
+	sql += orderBy
+
+	if filter.Limit > 0 {
+		sql += " LIMIT " + q.Arg(filter.Limit)
+	}
+
+	if (filter.Cursor == nil || fullText) && filter.Offset > 0 {
+		sql += " OFFSET " + q.Arg(filter.Offset)
+	}
+
+	rows, err := conn.Query(ctx, sql, q.Args()...)
 	if err != nil {
 		return nil, fmt.Errorf("failed get cats: %v", err)
 	}
@@ -113,7 +137,14 @@ func (p *Cat) FindAll(ctx context.Context, filter models.FilterGetCats, userID i
 
 	for rows.Next() {
 		cat := models.Cat{}
-		err := rows.Scan(&cat.Id, &cat.UserId, &cat.Name, &cat.Race, &cat.Sex, &cat.AgeInMonth, &cat.Description, &cat.ImageUrls, &cat.HasMatched, &cat.CreatedAt)
+		dest := []interface{}{&cat.Id, &cat.UserId, &cat.Name, &cat.Race, &cat.Sex, &cat.AgeInMonth, &cat.Description, &cat.ImageUrls, &cat.HasMatched, &cat.CreatedAt}
+
+		if fullText {
+			cat.Highlight = &models.CatHighlight{}
+			dest = append(dest, &cat.Highlight.Rank, &cat.Highlight.Name, &cat.Highlight.Description)
+		}
+
+		err := rows.Scan(dest...)
 		if err != nil {
 			return nil, fmt.Errorf("failed scan cats: %v", err)
 		}
@@ -133,10 +164,12 @@ func (p *Cat) Count(ctx context.Context, filter models.FilterGetCats, userID int
 
 	sql := `SELECT COUNT(id) FROM cats`
 
-	sql += p.constructWhereQuery(ctx, filter, userID)
+	q := p.constructWhereQuery(filter, userID)
+
+	sql += q.WhereSQL()
 
 	var count int
-	err = conn.QueryRow(ctx, sql).Scan(&count)
+	err = conn.QueryRow(ctx, sql, q.Args()...).Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed get cats count: %v", err)
 	}
@@ -187,7 +220,7 @@ func (p *Cat) Update(ctx context.Context, cat models.Cat) error {
 
 	sql := `
 		update cats set name = $1, race = $2, sex = $3, age_in_month = $4, description = $5, image_urls = $6, has_matched = $7, updated_at = now()
-		where id = $8 and user_id = $9
+		where id = $8 and user_id = $9 and deleted_at is null
 	`
 
 	_, err = conn.Exec(ctx, sql,
@@ -220,7 +253,7 @@ func (p *Cat) FindByID(ctx context.Context, catID int) (models.Cat, error) {
 
 	var cat models.Cat
 
-	err = conn.QueryRow(ctx, `SELECT id, user_id, name, race, sex, age_in_month, description, image_urls, has_matched, created_at FROM cats WHERE id = $1`, catID).Scan(
+	err = conn.QueryRow(ctx, `SELECT id, user_id, name, race, sex, age_in_month, description, image_urls, has_matched, created_at FROM cats WHERE id = $1 AND deleted_at IS NULL`, catID).Scan(
 		&cat.Id, &cat.UserId, &cat.Name, &cat.Race, &cat.Sex, &cat.AgeInMonth, &cat.Description, &cat.ImageUrls, &cat.HasMatched, &cat.CreatedAt,
 	)
 
@@ -244,7 +277,7 @@ func (p *Cat) FindByIDUser(ctx context.Context, catID int, userID int) (models.C
 
 	var cat models.Cat
 
-	err = conn.QueryRow(ctx, `SELECT id, user_id, name, race, sex, age_in_month, description, image_urls, has_matched, created_at FROM cats WHERE id = $1 AND user_id = $2`, catID, userID).Scan(
+	err = conn.QueryRow(ctx, `SELECT id, user_id, name, race, sex, age_in_month, description, image_urls, has_matched, created_at FROM cats WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL`, catID, userID).Scan(
 		&cat.Id, &cat.UserId, &cat.Name, &cat.Race, &cat.Sex, &cat.AgeInMonth, &cat.Description, &cat.ImageUrls, &cat.HasMatched, &cat.CreatedAt,
 	)
 	if err != nil {
@@ -257,19 +290,235 @@ func (p *Cat) FindByIDUser(ctx context.Context, catID int, userID int) (models.C
 	return cat, nil
 }
 
-func (p *Cat) DeleteByID(ctx context.Context, catID int) error {
+// FindRecommendations lists the cats that could match cat: unmatched, of the opposite sex,
+// from another owner not blocked either way and without a pending request with cat. They are ranked by race
+// match, then age closeness in years, then how many weeks ago their owner was last active.
+func (p *Cat) FindRecommendations(ctx context.Context, cat models.Cat, limit, offset int) ([]models.Cat, error) {
 	conn, err := p.dbPool.Acquire(ctx)
 	if err != nil {
-		return fmt.Errorf("failed acquire db connection from pool: %v", err)
+		return nil, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	sql := `
+		SELECT c.id, c.user_id, c.name, c.race, c.sex, c.age_in_month, c.description, c.image_urls, c.has_matched, c.created_at
+		FROM cats c
+		JOIN users u ON u.id = c.user_id
+		WHERE c.deleted_at IS NULL AND c.has_matched = FALSE AND c.sex != $1 AND c.user_id != $2
+		AND NOT EXISTS (
+			SELECT 1 FROM user_blocks b
+			WHERE (b.blocker_id = $2 AND b.blocked_id = c.user_id) OR (b.blocker_id = c.user_id AND b.blocked_id = $2)
+		)
+		AND NOT EXISTS (
+			SELECT 1 FROM matches m
+			WHERE m.status = 'pending'
+			AND ((m.user_cat_id = $3 AND m.match_cat_id = c.id) OR (m.match_cat_id = $3 AND m.user_cat_id = c.id))
+		)
+		ORDER BY
+			(CASE WHEN c.race = $4 THEN 1 ELSE 0 END)
+			+ 1.0 / (1 + ABS(c.age_in_month - $5) / 12.0)
+			+ 1.0 / (1 + EXTRACT(EPOCH FROM now() - u.last_active_at) / 604800) DESC,
+			c.created_at DESC, c.id DESC
+		LIMIT $6 OFFSET $7
+	`
+
+	rows, err := conn.Query(ctx, sql, cat.Sex, cat.UserId, cat.Id, cat.Race, cat.AgeInMonth, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed get recommendations: %v", err)
+	}
+
+	defer rows.Close()
+
+	cats := []models.Cat{}
+
+	for rows.Next() {
+		cat := models.Cat{}
+		err := rows.Scan(&cat.Id, &cat.UserId, &cat.Name, &cat.Race, &cat.Sex, &cat.AgeInMonth, &cat.Description, &cat.ImageUrls, &cat.HasMatched, &cat.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed scan cats: %v", err)
+		}
+		cats = append(cats, cat)
+	}
+
+	return cats, nil
+}
+
+// FindByIDsWithDeleted loads several cats at once keyed by id, missing ids are left out
+// of the map. Soft deleted cats are returned too, so that match history can still show them.
+func (p *Cat) FindByIDsWithDeleted(ctx context.Context, catIDs []int) (map[int]models.Cat, error) {
+	cats := make(map[int]models.Cat, len(catIDs))
+	if len(catIDs) == 0 {
+		return cats, nil
+	}
+
+	conn, err := p.dbPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed acquire db connection from pool: %v", err)
 	}
 
 	defer conn.Release()
 
-	sql := `delete from cats where id = $1`
-	_, err = conn.Exec(ctx, sql, catID)
+	rows, err := conn.Query(ctx, `SELECT id, user_id, name, race, sex, age_in_month, description, image_urls, has_matched, created_at, deleted_at FROM cats WHERE id = ANY($1)`, catIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed get cats: %v", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var cat models.Cat
+		err := rows.Scan(&cat.Id, &cat.UserId, &cat.Name, &cat.Race, &cat.Sex, &cat.AgeInMonth, &cat.Description, &cat.ImageUrls, &cat.HasMatched, &cat.CreatedAt, &cat.DeletedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed scan cats: %v", err)
+		}
+		cats[cat.Id] = cat
+	}
+
+	return cats, rows.Err()
+}
+
+// DeleteByID soft deletes a cat owned by userID and closes the pending match requests
+// involving it: requests the owner sent are withdrawn and requests they received are
+// rejected, and the other owners are notified. Approved matches are kept and keep
+// showing the cat.
+func (p *Cat) DeleteByID(ctx context.Context, catID int, userID int) error {
+	tx, err := p.dbPool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed begin transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `update cats set deleted_at = now(), updated_at = now() where id = $1 and user_id = $2 and deleted_at is null`, catID, userID)
 	if err != nil {
 		return fmt.Errorf("failed delete cat: %v", err)
 	}
 
+	if tag.RowsAffected() == 0 {
+		return ErrNoRow
+	}
+
+	pending, err := lockPendingMatches(ctx, tx, "match_cat_id = $1 OR user_cat_id = $1", catID)
+	if err != nil {
+		return err
+	}
+
+	for _, match := range pending {
+		to := MatchStatusRejected
+		if match.UserCatId == catID {
+			to = MatchStatusWithdrawn
+		}
+
+		if err := transition(ctx, tx, match, to, userID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed commit cat deletion: %v", err)
+	}
+
 	return nil
 }
+
+// Restore brings a soft deleted cat back.
+func (p *Cat) Restore(ctx context.Context, catID int) error {
+	conn, err := p.dbPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tag, err := conn.Exec(ctx, `update cats set deleted_at = null, updated_at = now() where id = $1 and deleted_at is not null`, catID)
+	if err != nil {
+		return fmt.Errorf("failed restore cat: %v", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRow
+	}
+
+	return nil
+}
+
+// PurgeDeleted hard deletes the cats soft deleted more than olderThan ago together
+// with their matches and returns the image urls they referenced. Cats of other users
+// matched with a purged cat are free to match again.
+func (p *Cat) PurgeDeleted(ctx context.Context, olderThan time.Duration) ([]string, error) {
+	tx, err := p.dbPool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed begin transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `
+		WITH purged AS (
+			SELECT id FROM cats WHERE deleted_at < now() - make_interval(secs => $1)
+		), removed AS (
+			DELETE FROM matches
+			WHERE user_cat_id IN (SELECT id FROM purged) OR match_cat_id IN (SELECT id FROM purged)
+			RETURNING id, user_cat_id, match_cat_id, status
+		), freed AS (
+			SELECT user_cat_id AS cat_id FROM removed WHERE status = 'approved'
+			UNION
+			SELECT match_cat_id FROM removed WHERE status = 'approved'
+		)
+		UPDATE cats c SET has_matched = FALSE, updated_at = now()
+		WHERE c.id IN (SELECT cat_id FROM freed) AND c.id NOT IN (SELECT id FROM purged) AND NOT EXISTS (
+			SELECT 1 FROM matches m
+			WHERE m.status = 'approved' AND (m.user_cat_id = c.id OR m.match_cat_id = c.id) AND m.id NOT IN (SELECT id FROM removed)
+		)
+	`, olderThan.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed delete matches of purged cats: %v", err)
+	}
+
+	rows, err := tx.Query(ctx, `DELETE FROM cats WHERE deleted_at < now() - make_interval(secs => $1) RETURNING image_urls`, olderThan.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed purge cats: %v", err)
+	}
+
+	imageUrls := []string{}
+	for rows.Next() {
+		var urls []string
+		if err := rows.Scan(&urls); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed scan purged cats: %v", err)
+		}
+		imageUrls = append(imageUrls, urls...)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed purge cats: %v", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed commit cat purge: %v", err)
+	}
+
+	return imageUrls, nil
+}
+
+// IsImageUsed reports whether any cat still references the image url. Soft deleted
+// cats count as well since they can be restored and are shown in match history.
+func (p *Cat) IsImageUsed(ctx context.Context, url string) (bool, error) {
+	conn, err := p.dbPool.Acquire(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	var used bool
+
+	err = conn.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM cats WHERE $1 = ANY(image_urls))`, url).Scan(&used)
+	if err != nil {
+		return false, fmt.Errorf("failed check image usage: %v", err)
+	}
+
+	return used, nil
+}
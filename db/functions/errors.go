@@ -1,9 +1,36 @@
 package functions
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
 
 var (
 	ErrNoRow          = errors.New("data not found")
 	ErrInsuficientQty = errors.New("insuficient quantity")
 	ErrUnauthorized   = errors.New("unauthorized")
+	ErrForbidden      = errors.New("forbidden")
+	ErrTokenInvalid   = errors.New("invalid refresh token")
+	ErrTokenExpired   = errors.New("refresh token expired")
+	ErrTokenReused    = errors.New("refresh token reused")
+	ErrAlreadyMatched = errors.New("cat is already matched")
+	ErrDuplicateMatch = errors.New("a pending match request already exists between these cats")
+	ErrTooManyPending = errors.New("the cat has too many pending match requests")
+
+	ErrUserTokenInvalid     = errors.New("invalid or expired token")
+	ErrUserTokenActive      = errors.New("a token was already sent and has not expired")
+	ErrEmailAlreadyVerified = errors.New("email already verified")
+	ErrEmailNotVerified     = errors.New("email not verified")
 )
+
+// uniqueViolation is the postgres error code raised when a unique constraint fails.
+const uniqueViolation = "23505"
+
+// foreignKeyViolation is the postgres error code raised when a referenced row is missing.
+const foreignKeyViolation = "23503"
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
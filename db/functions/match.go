@@ -1,39 +1,165 @@
 package functions
 
 import (
+	"CatsSocial/configs"
 	"CatsSocial/db/models"
 	"context"
 	"errors"
 	"fmt"
-	"strconv"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type Match struct {
+	config configs.Config
 	dbPool *pgxpool.Pool
 }
 
-func NewMatch(dbPool *pgxpool.Pool) *Match {
+func NewMatch(dbPool *pgxpool.Pool, config configs.Config) *Match {
 	return &Match{
+		config: config,
 		dbPool: dbPool,
 	}
 }
 
-func (m *Match) Create(ctx context.Context, match models.Match) error {
+// Create stores a new pending match request issued by match.UserId. When the other
+// owner already sent the reverse request, that request is approved instead and returned.
+// Only one request per pair of cats can be pending, a second one fails with ErrDuplicateMatch.
+func (m *Match) Create(ctx context.Context, match models.Match) (models.Match, error) {
+	tx, err := m.dbPool.Begin(ctx)
+	if err != nil {
+		return models.Match{}, fmt.Errorf("failed begin transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	// lapsed requests the expiry worker did not close yet must not block a new one
+	if err := expireLapsed(ctx, tx, []int{match.UserCatId, match.MatchCatId}); err != nil {
+		return models.Match{}, err
+	}
+
+	reciprocal, err := lockReciprocal(ctx, tx, match)
+	if err != nil && !errors.Is(err, ErrNoRow) {
+		return models.Match{}, err
+	}
+
+	if err == nil {
+		if err := approveLocked(ctx, tx, reciprocal, match.UserId); err != nil {
+			return models.Match{}, err
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			return models.Match{}, fmt.Errorf("failed commit approval: %v", err)
+		}
+
+		reciprocal.Status = MatchStatusApproved
+		return reciprocal, nil
+	}
+
+	if err := checkPendingLimit(ctx, tx, match.UserCatId, m.config.MatchMaxPendingPerCat); err != nil {
+		return models.Match{}, err
+	}
+
+	result := match
+	result.Status = MatchStatusPending
+
+	err = tx.QueryRow(ctx, `INSERT INTO matches (user_id, match_user_id, match_cat_id, user_cat_id, message, status, match_cat_snapshot, user_cat_snapshot, expires_at) values($1, $2, $3, $4, $5, $6, $7, $8, now() + make_interval(secs => $9)) RETURNING id, created_at, expires_at`,
+		match.UserId, match.MatchUserId, match.MatchCatId, match.UserCatId, match.Message, MatchStatusPending, match.MatchCatSnapshot, match.UserCatSnapshot, m.config.MatchRequestTTL.Seconds(),
+	).Scan(&result.Id, &result.CreatedAt, &result.ExpiresAt)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return models.Match{}, ErrDuplicateMatch
+		}
+		return models.Match{}, fmt.Errorf("failed insert match: %v", err)
+	}
+
+	if err := recordEvent(ctx, tx, result.Id, nil, MatchStatusPending, match.UserId); err != nil {
+		return models.Match{}, err
+	}
+
+	if err := notifyMatch(ctx, tx, result, MatchStatusPending, match.UserId); err != nil {
+		return models.Match{}, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return models.Match{}, fmt.Errorf("failed commit match: %v", err)
+	}
+
+	return result, nil
+}
+
+// checkPendingLimit refuses a new request from a cat that already has max live pending
+// requests out, a zero max means no limit. The cat is locked first so that concurrent
+// requests from it are counted one after the other.
+func checkPendingLimit(ctx context.Context, tx pgx.Tx, catId int, max int) error {
+	if max <= 0 {
+		return nil
+	}
+
+	if _, err := tx.Exec(ctx, `SELECT id FROM cats WHERE id = $1 FOR UPDATE`, catId); err != nil {
+		return fmt.Errorf("failed lock cat: %v", err)
+	}
+
+	rows, err := tx.Query(ctx, `SELECT id FROM matches WHERE user_cat_id = $1 AND status = 'pending' AND expires_at > now() FOR UPDATE`, catId)
+	if err != nil {
+		return fmt.Errorf("failed count pending matches: %v", err)
+	}
+
+	count := 0
+	for rows.Next() {
+		count++
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed count pending matches: %v", err)
+	}
+
+	if count >= max {
+		return ErrTooManyPending
+	}
+
+	return nil
+}
+
+// HasPendingReciprocal reports whether matchCatId has a live pending request out to
+// userCatId, a request sent back then approves it.
+func (m *Match) HasPendingReciprocal(ctx context.Context, userCatId, matchCatId int) (bool, error) {
 	conn, err := m.dbPool.Acquire(ctx)
 	if err != nil {
-		return fmt.Errorf("failed acquire connection from db pool: %v", err)
+		return false, fmt.Errorf("failed acquire connection from db pool: %v", err)
 	}
 
 	defer conn.Release()
 
-	_, err = conn.Exec(ctx, `INSERT INTO matches (user_id, match_user_id, match_cat_id, user_cat_id, message, status) values($1, $2, $3, $4, $5, $6)`,
-		match.UserId, match.MatchUserId, match.MatchCatId, match.UserCatId, match.Message, match.Status,
-	)
+	var exists bool
+	err = conn.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM matches WHERE user_cat_id = $1 AND match_cat_id = $2 AND status = 'pending' AND expires_at > now())`,
+		matchCatId, userCatId,
+	).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed get reciprocal match: %v", err)
+	}
+
+	return exists, nil
+}
+
+// lockReciprocal finds and locks the pending request going the other way between
+// the cats of match, it returns ErrNoRow when there is none.
+func lockReciprocal(ctx context.Context, tx pgx.Tx, match models.Match) (models.Match, error) {
+	var reciprocal models.Match
+
+	err := tx.QueryRow(ctx, `SELECT id, user_id, match_user_id, match_cat_id, user_cat_id, status, created_at, expires_at FROM matches WHERE user_cat_id = $1 AND match_cat_id = $2 AND status = 'pending' FOR UPDATE`,
+		match.MatchCatId, match.UserCatId,
+	).Scan(&reciprocal.Id, &reciprocal.UserId, &reciprocal.MatchUserId, &reciprocal.MatchCatId, &reciprocal.UserCatId, &reciprocal.Status, &reciprocal.CreatedAt, &reciprocal.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return reciprocal, ErrNoRow
+		}
+		return reciprocal, fmt.Errorf("failed get reciprocal match: %v", err)
+	}
 
-	return err
+	return reciprocal, nil
 }
 
 func (m *Match) Get(ctx context.Context, userId string) ([]models.Match, error) {
@@ -46,7 +172,7 @@ func (m *Match) Get(ctx context.Context, userId string) ([]models.Match, error)
 
 	defer conn.Release()
 
-	rows, err := conn.Query(ctx, `SELECT id, user_id, match_user_id, match_cat_id, user_cat_id, message, status, created_at FROM matches WHERE user_id = $1 AND status != 'removed'`, userId)
+	rows, err := conn.Query(ctx, `SELECT id, user_id, match_user_id, match_cat_id, user_cat_id, message, status, created_at FROM matches WHERE user_id = $1 AND status IN ('pending', 'approved')`, userId)
 	if err != nil {
 		return result, err
 	}
@@ -67,7 +193,7 @@ func (m *Match) Get(ctx context.Context, userId string) ([]models.Match, error)
 	return result, nil
 }
 
-func (m *Match) GetMatchById(ctx context.Context, matchId string) (models.Match, error) {
+func (m *Match) GetMatchById(ctx context.Context, matchId int) (models.Match, error) {
 	result := models.Match{}
 
 	conn, err := m.dbPool.Acquire(ctx)
@@ -77,8 +203,8 @@ func (m *Match) GetMatchById(ctx context.Context, matchId string) (models.Match,
 
 	defer conn.Release()
 
-	error_row := conn.QueryRow(ctx, `SELECT id, user_id, match_user_id, match_cat_id, user_cat_id, message, status, created_at FROM matches WHERE id = $1`, matchId).Scan(&result.Id, &result.UserId, &result.MatchUserId, &result.MatchCatId, &result.UserCatId, &result.Message, &result.Status, &result.CreatedAt)
-	if error_row != nil {
+	err = conn.QueryRow(ctx, `SELECT id, user_id, match_user_id, match_cat_id, user_cat_id, message, status, created_at, expires_at, match_cat_snapshot, user_cat_snapshot FROM matches WHERE id = $1`, matchId).Scan(&result.Id, &result.UserId, &result.MatchUserId, &result.MatchCatId, &result.UserCatId, &result.Message, &result.Status, &result.CreatedAt, &result.ExpiresAt, &result.MatchCatSnapshot, &result.UserCatSnapshot)
+	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return result, ErrNoRow
 		}
@@ -88,7 +214,43 @@ func (m *Match) GetMatchById(ctx context.Context, matchId string) (models.Match,
 	return result, nil
 }
 
-func (m *Match) GetRelatedMatches(ctx context.Context, userId string) ([]models.Match, error) {
+func (m *Match) constructWhereQuery(filter models.FilterGetMatches, userId int) *queryBuilder {
+	q := &queryBuilder{}
+
+	switch filter.Direction {
+	case models.MatchDirectionIncoming:
+		q.Where("match_user_id = " + q.Arg(userId))
+	case models.MatchDirectionOutgoing:
+		q.Where("user_id = " + q.Arg(userId))
+	default:
+		user := q.Arg(userId)
+		q.Where("(user_id = " + user + " OR match_user_id = " + user + ")")
+	}
+
+	statuses := filter.Statuses
+	if len(statuses) == 0 {
+		statuses = []string{MatchStatusPending, MatchStatusApproved, MatchStatusExpired}
+	}
+	q.Where("status = ANY(" + q.Arg(statuses) + ")")
+
+	if filter.CatId != 0 {
+		cat := q.Arg(filter.CatId)
+		q.Where("(match_cat_id = " + cat + " OR user_cat_id = " + cat + ")")
+	}
+
+	if filter.CreatedFrom != nil {
+		q.Where("created_at >= " + q.Arg(*filter.CreatedFrom))
+	}
+
+	if filter.CreatedTo != nil {
+		q.Where("created_at <= " + q.Arg(*filter.CreatedTo))
+	}
+
+	return q
+}
+
+// FindAll lists the matches a user issued or received, newest first.
+func (m *Match) FindAll(ctx context.Context, filter models.FilterGetMatches, userId int) ([]models.Match, error) {
 	result := []models.Match{}
 
 	conn, err := m.dbPool.Acquire(ctx)
@@ -98,9 +260,30 @@ func (m *Match) GetRelatedMatches(ctx context.Context, userId string) ([]models.
 
 	defer conn.Release()
 
-	rows, err := conn.Query(ctx, `SELECT id, user_id, match_user_id, match_cat_id, user_cat_id, message, status, created_at FROM matches WHERE (user_id = $1 OR match_user_id = $2) AND status != 'removed'`, userId, userId)
+	sql := `SELECT id, user_id, match_user_id, match_cat_id, user_cat_id, message, status, created_at, expires_at, match_cat_snapshot, user_cat_snapshot FROM matches`
+
+	q := m.constructWhereQuery(filter, userId)
+
+	// keyset pagination continues right after the last match of the previous page
+	if filter.Cursor != nil {
+		q.Where("(created_at, id) < (" + q.Arg(filter.Cursor.CreatedAt) + ", " + q.Arg(filter.Cursor.Id) + ")")
+	}
+
+	sql += q.WhereSQL()
+
+	sql += " ORDER BY created_at DESC, id DESC"
+
+	if filter.Limit > 0 {
+		sql += " LIMIT " + q.Arg(filter.Limit)
+	}
+
+	if filter.Cursor == nil && filter.Offset > 0 {
+		sql += " OFFSET " + q.Arg(filter.Offset)
+	}
+
+	rows, err := conn.Query(ctx, sql, q.Args()...)
 	if err != nil {
-		return result, err
+		return result, fmt.Errorf("failed get matches: %v", err)
 	}
 
 	defer rows.Close()
@@ -108,7 +291,7 @@ func (m *Match) GetRelatedMatches(ctx context.Context, userId string) ([]models.
 	for rows.Next() {
 		var match models.Match
 
-		err := rows.Scan(&match.Id, &match.UserId, &match.MatchUserId, &match.MatchCatId, &match.UserCatId, &match.Message, &match.Status, &match.CreatedAt)
+		err := rows.Scan(&match.Id, &match.UserId, &match.MatchUserId, &match.MatchCatId, &match.UserCatId, &match.Message, &match.Status, &match.CreatedAt, &match.ExpiresAt, &match.MatchCatSnapshot, &match.UserCatSnapshot)
 		if err != nil {
 			return []models.Match{}, err
 		}
@@ -119,6 +302,25 @@ func (m *Match) GetRelatedMatches(ctx context.Context, userId string) ([]models.
 	return result, nil
 }
 
+func (m *Match) Count(ctx context.Context, filter models.FilterGetMatches, userId int) (int, error) {
+	conn, err := m.dbPool.Acquire(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed acquire connection from db pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	q := m.constructWhereQuery(filter, userId)
+
+	var count int
+	err = conn.QueryRow(ctx, `SELECT COUNT(id) FROM matches`+q.WhereSQL(), q.Args()...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed get matches count: %v", err)
+	}
+
+	return count, nil
+}
+
 func (m *Match) GetRelatedCatMatches(ctx context.Context, catId int) ([]models.Match, error) {
 	result := []models.Match{}
 
@@ -129,7 +331,7 @@ func (m *Match) GetRelatedCatMatches(ctx context.Context, catId int) ([]models.M
 
 	defer conn.Release()
 
-	rows, err := conn.Query(ctx, `SELECT id, user_id, match_user_id, match_cat_id, user_cat_id, message, status, created_at FROM matches WHERE (match_cat_id = $1 OR user_cat_id = $2) AND (status != 'removed' OR status != 'approved')`, catId, catId)
+	rows, err := conn.Query(ctx, `SELECT id, user_id, match_user_id, match_cat_id, user_cat_id, message, status, created_at FROM matches WHERE (match_cat_id = $1 OR user_cat_id = $2) AND status = 'pending'`, catId, catId)
 	if err != nil {
 		return result, err
 	}
@@ -150,62 +352,227 @@ func (m *Match) GetRelatedCatMatches(ctx context.Context, catId int) ([]models.M
 	return result, nil
 }
 
-func (m *Match) Delete(ctx context.Context, userId, matchId string) error {
+// CountPending counts the live pending requests sent from a cat.
+func (m *Match) CountPending(ctx context.Context, catId int) (int, error) {
 	conn, err := m.dbPool.Acquire(ctx)
 	if err != nil {
-		return fmt.Errorf("failed acquire connection from dbpool: %v", err)
+		return 0, fmt.Errorf("failed acquire connection from db pool: %v", err)
 	}
 
 	defer conn.Release()
 
-	var match models.Match
+	var count int
+	err = conn.QueryRow(ctx, `SELECT COUNT(id) FROM matches WHERE user_cat_id = $1 AND status = 'pending' AND expires_at > now()`, catId).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed count pending matches: %v", err)
+	}
 
-	err = conn.QueryRow(ctx, `select user_id from matches where id = $1`, matchId).Scan(
-		&match.UserId,
-	)
+	return count, nil
+}
+
+// Approve approves a pending match, marks both cats as matched and supersedes every
+// other pending request involving either cat. Everything runs in one transaction
+// and both cats are locked so concurrent approvals of the same cat cannot both succeed.
+// Only the receiver of the match can approve it.
+func (m *Match) Approve(ctx context.Context, matchId int, receiverId int) error {
+	tx, err := m.dbPool.Begin(ctx)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return ErrNoRow
-		}
+		return fmt.Errorf("failed begin transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
 
+	match, err := lockMatch(ctx, tx, matchId)
+	if err != nil {
 		return err
 	}
 
-	if strconv.Itoa(match.UserId) != userId {
-		return ErrUnauthorized
+	if match.MatchUserId != receiverId {
+		return ErrForbidden
 	}
 
-	_, err = conn.Exec(ctx, "delete from matches where id = $1", matchId)
+	if err := approveLocked(ctx, tx, match, receiverId); err != nil {
+		return err
+	}
 
-	return err
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed commit approval: %v", err)
+	}
+
+	return nil
 }
 
-func (m *Match) UpdateStatus(ctx context.Context, e models.Match, status string) error {
-	conn, err := m.dbPool.Acquire(ctx)
+// approveLocked approves a match locked by the caller, see Approve.
+func approveLocked(ctx context.Context, tx pgx.Tx, match models.Match, receiverId int) error {
+	if err := checkMatchTransition(match, MatchStatusApproved); err != nil {
+		return err
+	}
+
+	catIds := []int{match.MatchCatId, match.UserCatId}
+
+	if err := lockCats(ctx, tx, catIds); err != nil {
+		return err
+	}
+
+	if err := transition(ctx, tx, match, MatchStatusApproved, receiverId); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(ctx, `UPDATE cats SET has_matched = TRUE, updated_at = now() WHERE id = ANY($1)`, catIds)
 	if err != nil {
-		return fmt.Errorf("faield acquire connection from dbpool: %v", err)
+		return fmt.Errorf("failed update matched cats: %v", err)
 	}
 
-	defer conn.Release()
+	_, err = tx.Exec(ctx, `
+		WITH superseded AS (
+			UPDATE matches SET status = 'superseded', updated_at = now()
+			WHERE id != $1 AND (match_cat_id = ANY($2) OR user_cat_id = ANY($2)) AND status = 'pending'
+			RETURNING id, user_id
+		), events AS (
+			INSERT INTO match_events (match_id, actor_user_id, from_status, to_status)
+			SELECT id, $3, 'pending', 'superseded' FROM superseded
+		)
+		INSERT INTO notifications (user_id, type, match_id, actor_user_id)
+		SELECT user_id, $4, id, $3 FROM superseded
+	`, match.Id, catIds, receiverId, matchNotifications[MatchStatusSuperseded].kind)
+	if err != nil {
+		return fmt.Errorf("failed supersede other matches: %v", err)
+	}
 
-	var match models.Match
+	return nil
+}
 
-	err = conn.QueryRow(ctx, `select user_id from matches where id = $1`, e.Id).Scan(
-		&match.UserId,
-	)
+// lockCats locks both cats of a match in id order, so two approvals never wait on
+// each other, and checks that they still exist and are not matched yet.
+func lockCats(ctx context.Context, tx pgx.Tx, catIds []int) error {
+	rows, err := tx.Query(ctx, `SELECT has_matched FROM cats WHERE id = ANY($1) AND deleted_at IS NULL ORDER BY id FOR UPDATE`, catIds)
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
-			return ErrNoRow
+		return fmt.Errorf("failed lock cats: %v", err)
+	}
+
+	defer rows.Close()
+
+	locked := 0
+	for rows.Next() {
+		var hasMatched bool
+		if err := rows.Scan(&hasMatched); err != nil {
+			return fmt.Errorf("failed scan cat: %v", err)
 		}
 
+		if hasMatched {
+			return ErrAlreadyMatched
+		}
+		locked++
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed lock cats: %v", err)
+	}
+
+	if locked != len(catIds) {
+		return ErrNoRow
+	}
+
+	return nil
+}
+
+// Reissue reopens an expired match request for another TTL with fresh snapshots
+// of both cats, only its issuer can do so.
+func (m *Match) Reissue(ctx context.Context, matchId int, issuerId int, matchCatSnapshot, userCatSnapshot models.CatSnapshot) error {
+	tx, err := m.dbPool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed begin transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	match, err := lockMatch(ctx, tx, matchId)
+	if err != nil {
+		return err
+	}
+
+	if match.UserId != issuerId {
+		return ErrForbidden
+	}
+
+	if err := checkMatchTransition(match, MatchStatusPending); err != nil {
+		return err
+	}
+
+	if err := lockCats(ctx, tx, []int{match.MatchCatId, match.UserCatId}); err != nil {
+		return err
+	}
+
+	if err := checkPendingLimit(ctx, tx, match.UserCatId, m.config.MatchMaxPendingPerCat); err != nil {
 		return err
 	}
 
-	if match.UserId != e.UserId {
-		return ErrUnauthorized
+	if err := transition(ctx, tx, match, MatchStatusPending, issuerId); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE matches SET expires_at = now() + make_interval(secs => $1), match_cat_snapshot = $2, user_cat_snapshot = $3 WHERE id = $4`,
+		m.config.MatchRequestTTL.Seconds(), matchCatSnapshot, userCatSnapshot, match.Id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed renew match: %v", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed commit match reissue: %v", err)
+	}
+
+	return nil
+}
+
+// expireLapsed expires the lapsed pending requests between the given cats.
+func expireLapsed(ctx context.Context, tx pgx.Tx, catIds []int) error {
+	_, err := tx.Exec(ctx, `
+		WITH expired AS (
+			UPDATE matches SET status = 'expired', updated_at = now()
+			WHERE status = 'pending' AND expires_at <= now() AND user_cat_id = ANY($1) AND match_cat_id = ANY($1)
+			RETURNING id, user_id
+		), events AS (
+			INSERT INTO match_events (match_id, actor_user_id, from_status, to_status)
+			SELECT id, NULL, 'pending', 'expired' FROM expired
+		)
+		INSERT INTO notifications (user_id, type, match_id)
+		SELECT user_id, $2, id FROM expired
+	`, catIds, matchNotifications[MatchStatusExpired].kind)
+	if err != nil {
+		return fmt.Errorf("failed expire lapsed matches: %v", err)
 	}
 
-	_, err = conn.Exec(ctx, `update matches set status = $1 where id = $2`, status, e.Id)
+	return nil
+}
+
+// ExpirePending moves every pending match past its expiry date to expired and
+// returns how many were expired.
+func (m *Match) ExpirePending(ctx context.Context) (int64, error) {
+	conn, err := m.dbPool.Acquire(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed acquire connection from db pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	// the events have no actor, the server expired the matches. Every expired
+	// match notifies its issuer once, so the inserted notifications count them.
+	tag, err := conn.Exec(ctx, `
+		WITH expired AS (
+			UPDATE matches SET status = 'expired', updated_at = now()
+			WHERE status = 'pending' AND expires_at <= now()
+			RETURNING id, user_id
+		), events AS (
+			INSERT INTO match_events (match_id, actor_user_id, from_status, to_status)
+			SELECT id, NULL, 'pending', 'expired' FROM expired
+		)
+		INSERT INTO notifications (user_id, type, match_id)
+		SELECT user_id, $1, id FROM expired
+	`, matchNotifications[MatchStatusExpired].kind)
+	if err != nil {
+		return 0, fmt.Errorf("failed expire matches: %v", err)
+	}
 
-	return err
+	return tag.RowsAffected(), nil
 }
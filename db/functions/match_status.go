@@ -0,0 +1,230 @@
+package functions
+
+import (
+	"CatsSocial/db/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Match statuses. A match starts pending and every other status is final, except
+// expired which the issuer can reopen.
+const (
+	MatchStatusPending    = "pending"
+	MatchStatusApproved   = "approved"
+	MatchStatusRejected   = "rejected"
+	MatchStatusWithdrawn  = "withdrawn"
+	MatchStatusSuperseded = "superseded"
+	MatchStatusExpired    = "expired"
+)
+
+// matchTransitions lists the statuses each status can move to.
+var matchTransitions = map[string][]string{
+	MatchStatusPending: {
+		MatchStatusApproved,
+		MatchStatusRejected,
+		MatchStatusWithdrawn,
+		MatchStatusSuperseded,
+		MatchStatusExpired,
+	},
+	MatchStatusExpired: {
+		MatchStatusPending,
+	},
+}
+
+var (
+	ErrInvalidTransition = errors.New("invalid match status transition")
+	ErrMatchExpired      = errors.New("match request expired")
+)
+
+// CanTransition reports whether a match can move from one status to another.
+func CanTransition(from, to string) bool {
+	for _, allowed := range matchTransitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+func checkTransition(from, to string) error {
+	if !CanTransition(from, to) {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, from, to)
+	}
+
+	return nil
+}
+
+// checkMatchTransition is checkTransition for a locked match, a pending match past
+// its expiry date is treated as expired even before the expiry worker catches it.
+func checkMatchTransition(match models.Match, to string) error {
+	lapsed := match.Status == MatchStatusPending && match.ExpiresAt != nil && !match.ExpiresAt.After(time.Now())
+	if lapsed && to != MatchStatusExpired {
+		return ErrMatchExpired
+	}
+
+	return checkTransition(match.Status, to)
+}
+
+// transition moves a locked match to a new status, records who did it and notifies
+// the other party.
+func transition(ctx context.Context, tx pgx.Tx, match models.Match, to string, actorId int) error {
+	if err := checkTransition(match.Status, to); err != nil {
+		return err
+	}
+
+	_, err := tx.Exec(ctx, `UPDATE matches SET status = $1, updated_at = now() WHERE id = $2`, to, match.Id)
+	if err != nil {
+		// only one request per pair of cats can be pending
+		if isUniqueViolation(err) {
+			return ErrDuplicateMatch
+		}
+		return fmt.Errorf("failed update match status: %v", err)
+	}
+
+	if err := recordEvent(ctx, tx, match.Id, &match.Status, to, actorId); err != nil {
+		return err
+	}
+
+	return notifyMatch(ctx, tx, match, to, actorId)
+}
+
+func recordEvent(ctx context.Context, tx pgx.Tx, matchId int, from *string, to string, actorId int) error {
+	_, err := tx.Exec(ctx, `INSERT INTO match_events (match_id, actor_user_id, from_status, to_status) VALUES ($1, $2, $3, $4)`,
+		matchId, actorId, from, to,
+	)
+	if err != nil {
+		return fmt.Errorf("failed record match event: %v", err)
+	}
+
+	return nil
+}
+
+// lockMatch reads a match and locks it until the end of the transaction.
+func lockMatch(ctx context.Context, tx pgx.Tx, matchId int) (models.Match, error) {
+	var match models.Match
+
+	err := tx.QueryRow(ctx, `SELECT id, user_id, match_user_id, match_cat_id, user_cat_id, status, expires_at FROM matches WHERE id = $1 FOR UPDATE`, matchId).Scan(
+		&match.Id, &match.UserId, &match.MatchUserId, &match.MatchCatId, &match.UserCatId, &match.Status, &match.ExpiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return match, ErrNoRow
+		}
+		return match, fmt.Errorf("failed get match: %v", err)
+	}
+
+	return match, nil
+}
+
+// lockPendingMatches reads the pending matches meeting cond and locks them until the
+// end of the transaction.
+func lockPendingMatches(ctx context.Context, tx pgx.Tx, cond string, args ...interface{}) ([]models.Match, error) {
+	rows, err := tx.Query(ctx, `SELECT id, user_id, match_user_id, match_cat_id, user_cat_id, status, expires_at FROM matches WHERE status = 'pending' AND (`+cond+`) ORDER BY id FOR UPDATE`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed get pending matches: %v", err)
+	}
+
+	defer rows.Close()
+
+	matches := []models.Match{}
+
+	for rows.Next() {
+		var match models.Match
+		if err := rows.Scan(&match.Id, &match.UserId, &match.MatchUserId, &match.MatchCatId, &match.UserCatId, &match.Status, &match.ExpiresAt); err != nil {
+			return nil, fmt.Errorf("failed scan pending matches: %v", err)
+		}
+		matches = append(matches, match)
+	}
+
+	return matches, rows.Err()
+}
+
+// changeStatus moves a match to a new status once authorize accepts the actor.
+func (m *Match) changeStatus(ctx context.Context, matchId int, to string, actorId int, authorize func(models.Match) error) error {
+	tx, err := m.dbPool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed begin transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	match, err := lockMatch(ctx, tx, matchId)
+	if err != nil {
+		return err
+	}
+
+	if err := authorize(match); err != nil {
+		return err
+	}
+
+	if err := checkMatchTransition(match, to); err != nil {
+		return err
+	}
+
+	if err := transition(ctx, tx, match, to, actorId); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed commit match status: %v", err)
+	}
+
+	return nil
+}
+
+// Reject rejects a pending match, only its receiver can do so.
+func (m *Match) Reject(ctx context.Context, matchId int, receiverId int) error {
+	return m.changeStatus(ctx, matchId, MatchStatusRejected, receiverId, func(match models.Match) error {
+		if match.MatchUserId != receiverId {
+			return ErrForbidden
+		}
+		return nil
+	})
+}
+
+// Withdraw withdraws a pending match, only its issuer can do so.
+func (m *Match) Withdraw(ctx context.Context, matchId int, issuerId int) error {
+	return m.changeStatus(ctx, matchId, MatchStatusWithdrawn, issuerId, func(match models.Match) error {
+		if match.UserId != issuerId {
+			return ErrForbidden
+		}
+		return nil
+	})
+}
+
+// GetEvents returns the status history of a match, oldest first.
+func (m *Match) GetEvents(ctx context.Context, matchId int) ([]models.MatchEvent, error) {
+	result := []models.MatchEvent{}
+
+	conn, err := m.dbPool.Acquire(ctx)
+	if err != nil {
+		return result, fmt.Errorf("failed acquire connection from db pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	rows, err := conn.Query(ctx, `SELECT id, match_id, actor_user_id, from_status, to_status, created_at FROM match_events WHERE match_id = $1 ORDER BY created_at, id`, matchId)
+	if err != nil {
+		return result, fmt.Errorf("failed get match events: %v", err)
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var event models.MatchEvent
+
+		err := rows.Scan(&event.Id, &event.MatchId, &event.ActorUserId, &event.FromStatus, &event.ToStatus, &event.CreatedAt)
+		if err != nil {
+			return []models.MatchEvent{}, err
+		}
+
+		result = append(result, event)
+	}
+
+	return result, nil
+}
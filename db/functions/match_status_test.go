@@ -0,0 +1,73 @@
+package functions
+
+import (
+	"CatsSocial/db/models"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCanTransition(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{MatchStatusPending, MatchStatusApproved, true},
+		{MatchStatusPending, MatchStatusRejected, true},
+		{MatchStatusPending, MatchStatusWithdrawn, true},
+		{MatchStatusPending, MatchStatusSuperseded, true},
+		{MatchStatusPending, MatchStatusExpired, true},
+		{MatchStatusPending, MatchStatusPending, false},
+		{MatchStatusExpired, MatchStatusPending, true},
+		{MatchStatusExpired, MatchStatusApproved, false},
+		{MatchStatusApproved, MatchStatusRejected, false},
+		{MatchStatusApproved, MatchStatusPending, false},
+		{MatchStatusRejected, MatchStatusPending, false},
+		{MatchStatusWithdrawn, MatchStatusPending, false},
+		{MatchStatusSuperseded, MatchStatusApproved, false},
+		{"unknown", MatchStatusApproved, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+"_to_"+tt.to, func(t *testing.T) {
+			if got := CanTransition(tt.from, tt.to); got != tt.want {
+				t.Errorf("CanTransition(%q, %q) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+
+			err := checkTransition(tt.from, tt.to)
+			if tt.want != (err == nil) {
+				t.Errorf("checkTransition(%q, %q) = %v", tt.from, tt.to, err)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidTransition) {
+				t.Errorf("checkTransition(%q, %q) = %v, want ErrInvalidTransition", tt.from, tt.to, err)
+			}
+		})
+	}
+}
+
+func TestCheckMatchTransition(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		match   models.Match
+		to      string
+		wantErr error
+	}{
+		{"pending", models.Match{Status: MatchStatusPending, ExpiresAt: &future}, MatchStatusApproved, nil},
+		{"pending without expiry", models.Match{Status: MatchStatusPending}, MatchStatusRejected, nil},
+		{"lapsed", models.Match{Status: MatchStatusPending, ExpiresAt: &past}, MatchStatusApproved, ErrMatchExpired},
+		{"lapsed to expired", models.Match{Status: MatchStatusPending, ExpiresAt: &past}, MatchStatusExpired, nil},
+		{"final", models.Match{Status: MatchStatusApproved}, MatchStatusRejected, ErrInvalidTransition},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkMatchTransition(tt.match, tt.to)
+			if tt.wantErr == nil && err != nil || tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("checkMatchTransition() = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
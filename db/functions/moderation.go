@@ -0,0 +1,201 @@
+package functions
+
+import (
+	"CatsSocial/db/models"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// reportTargets maps every report target to the table holding it.
+var reportTargets = map[string]string{
+	models.ReportTargetUser:  "users",
+	models.ReportTargetCat:   "cats",
+	models.ReportTargetMatch: "matches",
+}
+
+// Moderation holds the blocks between users and the reports waiting for an admin.
+type Moderation struct {
+	dbPool *pgxpool.Pool
+}
+
+func NewModeration(dbPool *pgxpool.Pool) *Moderation {
+	return &Moderation{
+		dbPool: dbPool,
+	}
+}
+
+// Block stops blockedId from interacting with blockerId, blocking twice is a no-op.
+// The pending requests between the two users are closed in the same transaction:
+// the ones the blocker sent are withdrawn and the ones they received are rejected.
+func (m *Moderation) Block(ctx context.Context, blockerId, blockedId int) error {
+	tx, err := m.dbPool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed begin transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `INSERT INTO user_blocks (blocker_id, blocked_id) VALUES ($1, $2) ON CONFLICT DO NOTHING`, blockerId, blockedId)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation {
+			return ErrNoRow
+		}
+		return fmt.Errorf("failed block user: %v", err)
+	}
+
+	pending, err := lockPendingMatches(ctx, tx, "(user_id = $1 AND match_user_id = $2) OR (user_id = $2 AND match_user_id = $1)", blockerId, blockedId)
+	if err != nil {
+		return err
+	}
+
+	for _, match := range pending {
+		to := MatchStatusRejected
+		if match.UserId == blockerId {
+			to = MatchStatusWithdrawn
+		}
+
+		if err := transition(ctx, tx, match, to, blockerId); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed commit block: %v", err)
+	}
+
+	return nil
+}
+
+func (m *Moderation) Unblock(ctx context.Context, blockerId, blockedId int) error {
+	conn, err := m.dbPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed acquire connection from db pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tag, err := conn.Exec(ctx, `DELETE FROM user_blocks WHERE blocker_id = $1 AND blocked_id = $2`, blockerId, blockedId)
+	if err != nil {
+		return fmt.Errorf("failed unblock user: %v", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRow
+	}
+
+	return nil
+}
+
+// IsBlocked reports whether either user blocked the other.
+func (m *Moderation) IsBlocked(ctx context.Context, userId, otherUserId int) (bool, error) {
+	conn, err := m.dbPool.Acquire(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed acquire connection from db pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	var blocked bool
+
+	err = conn.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM user_blocks
+			WHERE (blocker_id = $1 AND blocked_id = $2) OR (blocker_id = $2 AND blocked_id = $1)
+		)
+	`, userId, otherUserId).Scan(&blocked)
+	if err != nil {
+		return false, fmt.Errorf("failed check user block: %v", err)
+	}
+
+	return blocked, nil
+}
+
+// Report queues a report for the admins, the reported user, cat or match must exist.
+func (m *Moderation) Report(ctx context.Context, report models.Report) (models.Report, error) {
+	table, ok := reportTargets[report.TargetType]
+	if !ok {
+		return models.Report{}, fmt.Errorf("unknown report target %q", report.TargetType)
+	}
+
+	conn, err := m.dbPool.Acquire(ctx)
+	if err != nil {
+		return models.Report{}, fmt.Errorf("failed acquire connection from db pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	// table comes from reportTargets, never from the request
+	err = conn.QueryRow(ctx, `
+		INSERT INTO reports (reporter_id, target_type, target_id, reason)
+		SELECT $1, $2, $3, $4 WHERE EXISTS (SELECT 1 FROM `+table+` WHERE id = $3)
+		RETURNING id, status, created_at
+	`, report.ReporterId, report.TargetType, report.TargetId, report.Reason).Scan(&report.Id, &report.Status, &report.CreatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return models.Report{}, ErrNoRow
+		}
+		return models.Report{}, fmt.Errorf("failed insert report: %v", err)
+	}
+
+	return report, nil
+}
+
+// FindReports lists the moderation queue for a status, oldest first.
+func (m *Moderation) FindReports(ctx context.Context, status string, limit, offset int) ([]models.Report, error) {
+	conn, err := m.dbPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed acquire connection from db pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	rows, err := conn.Query(ctx, `
+		SELECT id, reporter_id, target_type, target_id, reason, status, created_at, resolved_at
+		FROM reports WHERE status = $1 ORDER BY created_at, id LIMIT $2 OFFSET $3
+	`, status, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed get reports: %v", err)
+	}
+
+	defer rows.Close()
+
+	reports := []models.Report{}
+
+	for rows.Next() {
+		var report models.Report
+		err := rows.Scan(&report.Id, &report.ReporterId, &report.TargetType, &report.TargetId, &report.Reason, &report.Status, &report.CreatedAt, &report.ResolvedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed scan reports: %v", err)
+		}
+		reports = append(reports, report)
+	}
+
+	return reports, nil
+}
+
+// ResolveReport closes an open report as resolved or dismissed.
+func (m *Moderation) ResolveReport(ctx context.Context, reportId int, status string) error {
+	conn, err := m.dbPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed acquire connection from db pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tag, err := conn.Exec(ctx, `UPDATE reports SET status = $1, resolved_at = now() WHERE id = $2 AND status = 'open'`, status, reportId)
+	if err != nil {
+		return fmt.Errorf("failed resolve report: %v", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRow
+	}
+
+	return nil
+}
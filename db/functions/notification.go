@@ -0,0 +1,141 @@
+package functions
+
+import (
+	"CatsSocial/db/models"
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Notification struct {
+	dbPool *pgxpool.Pool
+}
+
+func NewNotification(dbPool *pgxpool.Pool) *Notification {
+	return &Notification{
+		dbPool: dbPool,
+	}
+}
+
+// matchNotifications tells who hears about a match moving to a status and how:
+// the receiver learns about requests and withdrawals, the issuer about answers and
+// about requests that were superseded or expired.
+var matchNotifications = map[string]struct {
+	notifyIssuer bool
+	kind         string
+}{
+	MatchStatusPending:    {false, models.NotificationMatchRequested},
+	MatchStatusApproved:   {true, models.NotificationMatchApproved},
+	MatchStatusRejected:   {true, models.NotificationMatchRejected},
+	MatchStatusWithdrawn:  {false, models.NotificationMatchWithdrawn},
+	MatchStatusSuperseded: {true, models.NotificationMatchSuperseded},
+	MatchStatusExpired:    {true, models.NotificationMatchExpired},
+}
+
+// notifyMatch notifies the other party of a match that moved to status, in the
+// transaction that moved it. Statuses nobody hears about are ignored.
+func notifyMatch(ctx context.Context, tx pgx.Tx, match models.Match, status string, actorId int) error {
+	notification, ok := matchNotifications[status]
+	if !ok {
+		return nil
+	}
+
+	recipient := match.MatchUserId
+	if notification.notifyIssuer {
+		recipient = match.UserId
+	}
+
+	_, err := tx.Exec(ctx, `INSERT INTO notifications (user_id, type, match_id, actor_user_id) VALUES ($1, $2, $3, $4)`,
+		recipient, notification.kind, match.Id, actorId,
+	)
+	if err != nil {
+		return fmt.Errorf("failed insert notification: %v", err)
+	}
+
+	return nil
+}
+
+// FindAll lists the notifications of a user, newest first.
+func (n *Notification) FindAll(ctx context.Context, userId int, unreadOnly bool, limit, offset int) ([]models.Notification, error) {
+	conn, err := n.dbPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed acquire connection from db pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	q := &queryBuilder{}
+	q.Where("user_id = " + q.Arg(userId))
+
+	if unreadOnly {
+		q.Where("read_at IS NULL")
+	}
+
+	sql := `SELECT id, user_id, type, match_id, actor_user_id, read_at, created_at FROM notifications` + q.WhereSQL() +
+		" ORDER BY created_at DESC, id DESC LIMIT " + q.Arg(limit) + " OFFSET " + q.Arg(offset)
+
+	rows, err := conn.Query(ctx, sql, q.Args()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed get notifications: %v", err)
+	}
+
+	defer rows.Close()
+
+	notifications := []models.Notification{}
+
+	for rows.Next() {
+		var notification models.Notification
+		err := rows.Scan(&notification.Id, &notification.UserId, &notification.Type, &notification.MatchId, &notification.ActorUserId, &notification.ReadAt, &notification.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed scan notifications: %v", err)
+		}
+		notifications = append(notifications, notification)
+	}
+
+	return notifications, nil
+}
+
+func (n *Notification) CountUnread(ctx context.Context, userId int) (int, error) {
+	conn, err := n.dbPool.Acquire(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed acquire connection from db pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	var count int
+	err = conn.QueryRow(ctx, `SELECT COUNT(id) FROM notifications WHERE user_id = $1 AND read_at IS NULL`, userId).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed count unread notifications: %v", err)
+	}
+
+	return count, nil
+}
+
+// MarkRead marks the given notifications of a user as read, or all of them when
+// ids is empty.
+func (n *Notification) MarkRead(ctx context.Context, userId int, ids []int) error {
+	conn, err := n.dbPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed acquire connection from db pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	q := &queryBuilder{}
+	q.Where("user_id = " + q.Arg(userId))
+	q.Where("read_at IS NULL")
+
+	if len(ids) > 0 {
+		q.Where("id = ANY(" + q.Arg(ids) + ")")
+	}
+
+	_, err = conn.Exec(ctx, `UPDATE notifications SET read_at = now()`+q.WhereSQL(), q.Args()...)
+	if err != nil {
+		return fmt.Errorf("failed mark notifications read: %v", err)
+	}
+
+	return nil
+}
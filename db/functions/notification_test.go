@@ -0,0 +1,42 @@
+package functions
+
+import (
+	"CatsSocial/db/models"
+	"testing"
+)
+
+func TestMatchNotifications(t *testing.T) {
+	tests := []struct {
+		status       string
+		kind         string
+		notifyIssuer bool
+	}{
+		{MatchStatusPending, models.NotificationMatchRequested, false},
+		{MatchStatusApproved, models.NotificationMatchApproved, true},
+		{MatchStatusRejected, models.NotificationMatchRejected, true},
+		{MatchStatusWithdrawn, models.NotificationMatchWithdrawn, false},
+		{MatchStatusSuperseded, models.NotificationMatchSuperseded, true},
+		{MatchStatusExpired, models.NotificationMatchExpired, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.status, func(t *testing.T) {
+			notification, ok := matchNotifications[tt.status]
+			if !ok {
+				t.Fatalf("nobody is notified of %s matches", tt.status)
+			}
+			if notification.kind != tt.kind || notification.notifyIssuer != tt.notifyIssuer {
+				t.Errorf("%s notifies %+v, want kind %s, issuer %v", tt.status, notification, tt.kind, tt.notifyIssuer)
+			}
+		})
+	}
+
+	// every status a match can move to is heard of by one of its owners
+	for _, statuses := range matchTransitions {
+		for _, status := range statuses {
+			if _, ok := matchNotifications[status]; !ok {
+				t.Errorf("nobody is notified of %s matches", status)
+			}
+		}
+	}
+}
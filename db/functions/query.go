@@ -0,0 +1,39 @@
+package functions
+
+import (
+	"strconv"
+	"strings"
+)
+
+// queryBuilder collects WHERE conditions together with their positional
+// arguments so that user input never ends up concatenated into raw SQL.
+type queryBuilder struct {
+	conditions []string
+	args       []interface{}
+}
+
+// Arg registers a value and returns its "$n" placeholder.
+func (q *queryBuilder) Arg(value interface{}) string {
+	q.args = append(q.args, value)
+	return "$" + strconv.Itoa(len(q.args))
+}
+
+// Where appends a condition, the condition should reference values through Arg.
+func (q *queryBuilder) Where(condition string) *queryBuilder {
+	q.conditions = append(q.conditions, condition)
+	return q
+}
+
+// WhereSQL returns the WHERE clause, or an empty string if there is no condition.
+func (q *queryBuilder) WhereSQL() string {
+	if len(q.conditions) == 0 {
+		return ""
+	}
+
+	return " WHERE " + strings.Join(q.conditions, " AND ")
+}
+
+// Args returns the arguments in placeholder order.
+func (q *queryBuilder) Args() []interface{} {
+	return q.args
+}
@@ -0,0 +1,70 @@
+package functions
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQueryBuilder(t *testing.T) {
+	tests := []struct {
+		name      string
+		build     func(q *queryBuilder)
+		wantWhere string
+		wantArgs  []interface{}
+	}{
+		{
+			name:      "no condition",
+			build:     func(q *queryBuilder) {},
+			wantWhere: "",
+		},
+		{
+			name: "one condition",
+			build: func(q *queryBuilder) {
+				q.Where("id = " + q.Arg(7))
+			},
+			wantWhere: " WHERE id = $1",
+			wantArgs:  []interface{}{7},
+		},
+		{
+			name: "conditions are joined with AND",
+			build: func(q *queryBuilder) {
+				q.Where("sex = " + q.Arg("male")).Where("has_matched = " + q.Arg(false))
+			},
+			wantWhere: " WHERE sex = $1 AND has_matched = $2",
+			wantArgs:  []interface{}{"male", false},
+		},
+		{
+			name: "an argument used twice",
+			build: func(q *queryBuilder) {
+				user := q.Arg(3)
+				q.Where("(user_id = " + user + " OR match_user_id = " + user + ")")
+				q.Where("deleted_at IS NULL")
+			},
+			wantWhere: " WHERE (user_id = $1 OR match_user_id = $1) AND deleted_at IS NULL",
+			wantArgs:  []interface{}{3},
+		},
+		{
+			name: "user input stays an argument",
+			build: func(q *queryBuilder) {
+				q.Where("name ILIKE " + q.Arg("%'; DROP TABLE cats; --%"))
+			},
+			wantWhere: " WHERE name ILIKE $1",
+			wantArgs:  []interface{}{"%'; DROP TABLE cats; --%"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &queryBuilder{}
+			tt.build(q)
+
+			if got := q.WhereSQL(); got != tt.wantWhere {
+				t.Errorf("WhereSQL() = %q, want %q", got, tt.wantWhere)
+			}
+
+			if got := q.Args(); !reflect.DeepEqual(got, tt.wantArgs) {
+				t.Errorf("Args() = %v, want %v", got, tt.wantArgs)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package functions
+
+import (
+	"CatsSocial/db/models"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// raceCacheTTL bounds how long a newly inserted race takes to become valid.
+const raceCacheTTL = 5 * time.Minute
+
+// Race serves the race catalogue, cached in memory since it is read by every
+// cat validation and rarely changes.
+type Race struct {
+	dbPool *pgxpool.Pool
+
+	mu        sync.RWMutex
+	races     []models.Race
+	expiresAt time.Time
+}
+
+func NewRace(dbPool *pgxpool.Pool) *Race {
+	return &Race{
+		dbPool: dbPool,
+	}
+}
+
+func (r *Race) FindAll(ctx context.Context) ([]models.Race, error) {
+	r.mu.RLock()
+	if time.Now().Before(r.expiresAt) {
+		races := r.races
+		r.mu.RUnlock()
+		return races, nil
+	}
+	r.mu.RUnlock()
+
+	races, err := r.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.races = races
+	r.expiresAt = time.Now().Add(raceCacheTTL)
+	r.mu.Unlock()
+
+	return races, nil
+}
+
+// Names returns the name of every race, used to validate cat payloads.
+func (r *Race) Names(ctx context.Context) ([]string, error) {
+	races, err := r.FindAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(races))
+	for _, race := range races {
+		names = append(names, race.Name)
+	}
+
+	return names, nil
+}
+
+func (r *Race) load(ctx context.Context) ([]models.Race, error) {
+	conn, err := r.dbPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	rows, err := conn.Query(ctx, `SELECT id, name, COALESCE(size, ''), COALESCE(coat, ''), created_at FROM races ORDER BY name`)
+	if err != nil {
+		return nil, fmt.Errorf("failed get races: %v", err)
+	}
+
+	defer rows.Close()
+
+	races := []models.Race{}
+
+	for rows.Next() {
+		race := models.Race{}
+		err := rows.Scan(&race.Id, &race.Name, &race.Size, &race.Coat, &race.CreatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("failed scan races: %v", err)
+		}
+		races = append(races, race)
+	}
+
+	return races, nil
+}
@@ -0,0 +1,229 @@
+package functions
+
+import (
+	"CatsSocial/configs"
+	"CatsSocial/db/models"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Token struct {
+	config configs.Config
+	dbPool *pgxpool.Pool
+}
+
+func NewToken(dbPool *pgxpool.Pool, config configs.Config) *Token {
+	return &Token{
+		dbPool: dbPool,
+		config: config,
+	}
+}
+
+// hashToken returns the value stored in the database, raw refresh tokens are never persisted.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func newRawToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func (t *Token) insertRefreshToken(ctx context.Context, q pgx.Tx, userId int, familyId string) (string, error) {
+	raw, err := newRawToken()
+	if err != nil {
+		return "", fmt.Errorf("failed generate refresh token: %v", err)
+	}
+
+	_, err = q.Exec(ctx, `INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at) VALUES ($1, $2, $3, now() + make_interval(secs => $4))`,
+		userId, familyId, hashToken(raw), t.config.RefreshTokenTTL.Seconds(),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed insert refresh token: %v", err)
+	}
+
+	// signing in and refreshing are what tells an owner is still around
+	_, err = q.Exec(ctx, `UPDATE users SET last_active_at = now() WHERE id = $1`, userId)
+	if err != nil {
+		return "", fmt.Errorf("failed update user activity: %v", err)
+	}
+
+	return raw, nil
+}
+
+// CreateRefreshToken starts a new token family for the user, e.g. on login.
+func (t *Token) CreateRefreshToken(ctx context.Context, userId int) (string, error) {
+	tx, err := t.dbPool.Begin(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed begin transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	raw, err := t.insertRefreshToken(ctx, tx, userId, uuid.NewString())
+	if err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return "", fmt.Errorf("failed commit refresh token: %v", err)
+	}
+
+	return raw, nil
+}
+
+// Rotate exchanges a refresh token for a new one of the same family. Presenting a
+// token that was already rotated means it leaked, so the whole family is revoked.
+func (t *Token) Rotate(ctx context.Context, token string) (int, string, error) {
+	tx, err := t.dbPool.Begin(ctx)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed begin transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	var (
+		current models.RefreshToken
+		expired bool
+	)
+
+	err = tx.QueryRow(ctx, `SELECT id, user_id, family_id::text, expires_at, revoked_at, expires_at <= now() FROM refresh_tokens WHERE token_hash = $1 FOR UPDATE`, hashToken(token)).Scan(
+		&current.Id, &current.UserId, &current.FamilyId, &current.ExpiresAt, &current.RevokedAt, &expired,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, "", ErrTokenInvalid
+		}
+		return 0, "", fmt.Errorf("failed get refresh token: %v", err)
+	}
+
+	if current.RevokedAt != nil {
+		_, err = tx.Exec(ctx, `UPDATE refresh_tokens SET revoked_at = now() WHERE family_id = $1 AND revoked_at IS NULL`, current.FamilyId)
+		if err != nil {
+			return 0, "", fmt.Errorf("failed revoke token family: %v", err)
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			return 0, "", fmt.Errorf("failed commit token revocation: %v", err)
+		}
+
+		return 0, "", ErrTokenReused
+	}
+
+	if expired {
+		return 0, "", ErrTokenExpired
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE refresh_tokens SET revoked_at = now() WHERE id = $1`, current.Id)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed revoke refresh token: %v", err)
+	}
+
+	raw, err := t.insertRefreshToken(ctx, tx, current.UserId, current.FamilyId)
+	if err != nil {
+		return 0, "", err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, "", fmt.Errorf("failed commit refresh token: %v", err)
+	}
+
+	return current.UserId, raw, nil
+}
+
+// RevokeRefreshToken revokes the family of the given refresh token, it only
+// succeeds for tokens owned by userId.
+func (t *Token) RevokeRefreshToken(ctx context.Context, token string, userId int) error {
+	conn, err := t.dbPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tag, err := conn.Exec(ctx, `
+		UPDATE refresh_tokens SET revoked_at = now()
+		WHERE revoked_at IS NULL AND family_id = (SELECT family_id FROM refresh_tokens WHERE token_hash = $1 AND user_id = $2)
+	`, hashToken(token), userId)
+	if err != nil {
+		return fmt.Errorf("failed revoke refresh token: %v", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrTokenInvalid
+	}
+
+	return nil
+}
+
+// RevokeAccessToken blocks an access token until it would have expired anyway.
+func (t *Token) RevokeAccessToken(ctx context.Context, jti string, expiresAt int64) error {
+	conn, err := t.dbPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, `INSERT INTO revoked_tokens (jti, expires_at) VALUES ($1, to_timestamp($2)) ON CONFLICT (jti) DO NOTHING`, jti, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed revoke access token: %v", err)
+	}
+
+	// expired tokens are already rejected by the exp claim, no need to keep them
+	_, err = conn.Exec(ctx, `DELETE FROM revoked_tokens WHERE expires_at < now()`)
+	if err != nil {
+		return fmt.Errorf("failed clean revoked tokens: %v", err)
+	}
+
+	return nil
+}
+
+func (t *Token) IsAccessTokenRevoked(ctx context.Context, jti string) (bool, error) {
+	conn, err := t.dbPool.Acquire(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	var revoked bool
+
+	err = conn.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM revoked_tokens WHERE jti = $1)`, jti).Scan(&revoked)
+	if err != nil {
+		return false, fmt.Errorf("failed check revoked token: %v", err)
+	}
+
+	return revoked, nil
+}
+
+// RevokeAllRefreshTokens signs the user out of every device, e.g. after a password change.
+func (t *Token) RevokeAllRefreshTokens(ctx context.Context, userId int) error {
+	conn, err := t.dbPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed acquire db connection from pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, `UPDATE refresh_tokens SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL`, userId)
+	if err != nil {
+		return fmt.Errorf("failed revoke refresh tokens: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,218 @@
+package functions
+
+import (
+	"CatsSocial/db/models"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// User token purposes, a token only works for the purpose it was issued for.
+const (
+	UserTokenVerifyEmail   = "verify_email"
+	UserTokenResetPassword = "reset_password"
+)
+
+// createUserToken issues a token for purpose, mailed to the current email of usr.
+// Tokens issued before for the same purpose stop working. With cooldown set, no new
+// token is issued while an unused one has not expired, ErrUserTokenActive is returned.
+func (u *User) createUserToken(ctx context.Context, usr models.User, purpose string, ttl time.Duration, cooldown bool) (string, error) {
+	raw, err := newRawToken()
+	if err != nil {
+		return "", fmt.Errorf("failed generate user token: %v", err)
+	}
+
+	tx, err := u.dbPool.Begin(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed begin transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	if cooldown {
+		// the user row serializes concurrent requests for the same user
+		if _, err := tx.Exec(ctx, `SELECT id FROM users WHERE id = $1 FOR UPDATE`, usr.Id); err != nil {
+			return "", fmt.Errorf("failed lock user: %v", err)
+		}
+
+		var active bool
+		err = tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM user_tokens WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > now())`,
+			usr.Id, purpose,
+		).Scan(&active)
+		if err != nil {
+			return "", fmt.Errorf("failed get user tokens: %v", err)
+		}
+
+		if active {
+			return "", ErrUserTokenActive
+		}
+	}
+
+	_, err = tx.Exec(ctx, `DELETE FROM user_tokens WHERE user_id = $1 AND purpose = $2`, usr.Id, purpose)
+	if err != nil {
+		return "", fmt.Errorf("failed delete user tokens: %v", err)
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO user_tokens (user_id, purpose, token_hash, email, expires_at) VALUES ($1, $2, $3, $4, now() + make_interval(secs => $5))`,
+		usr.Id, purpose, hashToken(raw), usr.Email, ttl.Seconds(),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed insert user token: %v", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return "", fmt.Errorf("failed commit user token: %v", err)
+	}
+
+	return raw, nil
+}
+
+// consumeUserToken uses up a valid token for purpose and returns the user and the
+// email it was issued to.
+func consumeUserToken(ctx context.Context, tx pgx.Tx, token, purpose string) (int, string, error) {
+	var (
+		userId int
+		email  string
+	)
+
+	err := tx.QueryRow(ctx, `
+		UPDATE user_tokens SET used_at = now()
+		WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > now()
+		RETURNING user_id, email
+	`, hashToken(token), purpose).Scan(&userId, &email)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, "", ErrUserTokenInvalid
+		}
+		return 0, "", fmt.Errorf("failed use user token: %v", err)
+	}
+
+	return userId, email, nil
+}
+
+// CreateVerificationToken issues the token that verifies the email of a user, it
+// returns the user to mail it to.
+func (u *User) CreateVerificationToken(ctx context.Context, userID string) (string, models.User, error) {
+	usr, err := u.GetUserById(ctx, userID)
+	if err != nil {
+		return "", usr, err
+	}
+
+	if usr.EmailVerifiedAt != nil {
+		return "", usr, ErrEmailAlreadyVerified
+	}
+
+	raw, err := u.createUserToken(ctx, usr, UserTokenVerifyEmail, u.config.EmailVerifyTokenTTL, false)
+	if err != nil {
+		return "", usr, err
+	}
+
+	return raw, usr, nil
+}
+
+// VerifyEmail marks the email a verification token was mailed to as verified.
+func (u *User) VerifyEmail(ctx context.Context, token string) error {
+	tx, err := u.dbPool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed begin transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	userId, email, err := consumeUserToken(ctx, tx, token, UserTokenVerifyEmail)
+	if err != nil {
+		return err
+	}
+
+	// a token mailed to a previous address verifies nothing
+	tag, err := tx.Exec(ctx, `UPDATE users SET email_verified_at = COALESCE(email_verified_at, now()) WHERE id = $1 AND email = $2`, userId, email)
+	if err != nil {
+		return fmt.Errorf("failed verify email: %v", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrUserTokenInvalid
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed commit email verification: %v", err)
+	}
+
+	return nil
+}
+
+// CreatePasswordResetToken issues the token that resets the password of the user
+// with email, it returns ErrNoRow when nobody uses that email and ErrUserTokenActive
+// while the token sent before is still valid.
+func (u *User) CreatePasswordResetToken(ctx context.Context, email string) (string, models.User, error) {
+	conn, err := u.dbPool.Acquire(ctx)
+	if err != nil {
+		return "", models.User{}, err
+	}
+
+	var (
+		id  int
+		usr models.User
+	)
+
+	err = conn.QueryRow(ctx, `SELECT id, email, name FROM users WHERE email = $1`, email).Scan(&id, &usr.Email, &usr.Name)
+	conn.Release()
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", usr, ErrNoRow
+	}
+	if err != nil {
+		return "", usr, err
+	}
+
+	usr.Id = strconv.Itoa(id)
+
+	raw, err := u.createUserToken(ctx, usr, UserTokenResetPassword, u.config.PasswordResetTokenTTL, true)
+	if err != nil {
+		return "", usr, err
+	}
+
+	return raw, usr, nil
+}
+
+// ResetPassword replaces the password of the user a reset token was mailed to and
+// returns their id. Receiving the token also proves the email belongs to them.
+func (u *User) ResetPassword(ctx context.Context, token, newPassword string) (int, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(newPassword), u.config.BcryptSalt)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := u.dbPool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed begin transaction: %v", err)
+	}
+
+	defer tx.Rollback(ctx)
+
+	userId, email, err := consumeUserToken(ctx, tx, token, UserTokenResetPassword)
+	if err != nil {
+		return 0, err
+	}
+
+	tag, err := tx.Exec(ctx, `UPDATE users SET password = $1, email_verified_at = COALESCE(email_verified_at, now()) WHERE id = $2 AND email = $3`,
+		string(hashed), userId, email,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed reset password: %v", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return 0, ErrUserTokenInvalid
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed commit password reset: %v", err)
+	}
+
+	return userId, nil
+}
@@ -5,8 +5,10 @@ import (
 	"CatsSocial/db/models"
 	"context"
 	"errors"
+	"strconv"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -100,13 +102,191 @@ func (u *User) GetUserById(ctx context.Context, userID string) (models.User, err
 
 	var result models.User
 
-	err = conn.QueryRow(ctx, `SELECT id, email, name, created_at FROM users WHERE id = $1`, userID).Scan(&result.Id, &result.Email, &result.Name, &result.CreatedAt)
+	err = conn.QueryRow(ctx, `SELECT id, email, name, is_admin, created_at, email_notifications, email_verified_at FROM users WHERE id = $1`, userID).Scan(
+		&result.Id, &result.Email, &result.Name, &result.IsAdmin, &result.CreatedAt, &result.EmailNotifications, &result.EmailVerifiedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return result, ErrNoRow
+	}
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// GetUsersByIds loads several users at once keyed by id, missing ids are left out of the map.
+func (u *User) GetUsersByIds(ctx context.Context, userIDs []int) (map[int]models.User, error) {
+	users := make(map[int]models.User, len(userIDs))
+	if len(userIDs) == 0 {
+		return users, nil
+	}
+
+	conn, err := u.dbPool.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Release()
+
+	rows, err := conn.Query(ctx, `SELECT id, email, name, is_admin, created_at, email_notifications FROM users WHERE id = ANY($1)`, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id   int
+			user models.User
+		)
+		if err := rows.Scan(&id, &user.Email, &user.Name, &user.IsAdmin, &user.CreatedAt, &user.EmailNotifications); err != nil {
+			return nil, err
+		}
+		user.Id = strconv.Itoa(id)
+		users[id] = user
+	}
+
+	return users, rows.Err()
+}
+
+// UpdateProfile changes the name, email and email preference of a user, the email
+// must stay unique. A new email has to be verified again.
+func (u *User) UpdateProfile(ctx context.Context, usr models.User) (models.User, error) {
+	conn, err := u.dbPool.Acquire(ctx)
+	if err != nil {
+		return models.User{}, err
+	}
+	defer conn.Release()
+
+	var existingId string
+
+	err = conn.QueryRow(ctx, `SELECT id FROM users WHERE email = $1 AND id != $2`, usr.Email, usr.Id).Scan(&existingId)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return models.User{}, err
+	}
+	if existingId != "" {
+		return models.User{}, errors.New("EXISTING_EMAIL")
+	}
+
+	var result models.User
+
+	err = conn.QueryRow(ctx, `
+		UPDATE users SET email = $1, name = $2, email_notifications = $3, email_verified_at = CASE WHEN email = $1 THEN email_verified_at END
+		WHERE id = $4
+		RETURNING id, email, name, created_at, email_notifications, email_verified_at
+	`, usr.Email, usr.Name, usr.EmailNotifications, usr.Id).Scan(
+		&result.Id, &result.Email, &result.Name, &result.CreatedAt, &result.EmailNotifications, &result.EmailVerifiedAt,
+	)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return result, ErrNoRow
 	}
 	if err != nil {
+		// the unique constraint still guards against a concurrent registration
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return result, errors.New("EXISTING_EMAIL")
+		}
 		return result, err
 	}
 
 	return result, nil
 }
+
+// ChangePassword replaces the password of a user after checking the current one.
+func (u *User) ChangePassword(ctx context.Context, userID, oldPassword, newPassword string) error {
+	conn, err := u.dbPool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	var hashed string
+
+	err = conn.QueryRow(ctx, `SELECT password FROM users WHERE id = $1`, userID).Scan(&hashed)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNoRow
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hashed), []byte(oldPassword)); err != nil {
+		return errors.New("INVALID_PASSWORD")
+	}
+
+	newHashed, err := bcrypt.GenerateFromPassword([]byte(newPassword), u.config.BcryptSalt)
+	if err != nil {
+		return err
+	}
+
+	_, err = conn.Exec(ctx, `UPDATE users SET password = $1 WHERE id = $2`, string(newHashed), userID)
+
+	return err
+}
+
+// Delete removes a user together with their cats, every match involving those
+// cats and their refresh tokens. It returns the image urls of the removed cats.
+func (u *User) Delete(ctx context.Context, userID string) ([]string, error) {
+	tx, err := u.dbPool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	// cats of other users that were matched with one of the deleted cats are free to
+	// match again, unless they still have another approved match
+	_, err = tx.Exec(ctx, `
+		WITH removed AS (
+			DELETE FROM matches
+			WHERE user_id = $1 OR match_user_id = $1 OR user_cat_id IN (SELECT id FROM cats WHERE user_id = $1) OR match_cat_id IN (SELECT id FROM cats WHERE user_id = $1)
+			RETURNING id, user_cat_id, match_cat_id, status
+		), freed AS (
+			SELECT user_cat_id AS cat_id FROM removed WHERE status = 'approved'
+			UNION
+			SELECT match_cat_id FROM removed WHERE status = 'approved'
+		)
+		UPDATE cats c SET has_matched = FALSE, updated_at = now()
+		WHERE c.id IN (SELECT cat_id FROM freed) AND c.user_id <> $1 AND NOT EXISTS (
+			SELECT 1 FROM matches m
+			WHERE m.status = 'approved' AND (m.user_cat_id = c.id OR m.match_cat_id = c.id) AND m.id NOT IN (SELECT id FROM removed)
+		)
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(ctx, `DELETE FROM cats WHERE user_id = $1 RETURNING image_urls`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	imageUrls := []string{}
+	for rows.Next() {
+		var urls []string
+		if err := rows.Scan(&urls); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		imageUrls = append(imageUrls, urls...)
+	}
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(ctx, `DELETE FROM refresh_tokens WHERE user_id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	tag, err := tx.Exec(ctx, `DELETE FROM users WHERE id = $1`, userID)
+	if err != nil {
+		return nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return nil, ErrNoRow
+	}
+
+	return imageUrls, tx.Commit(ctx)
+}
@@ -0,0 +1,275 @@
+package functions
+
+import (
+	"CatsSocial/db/models"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type Webhook struct {
+	dbPool *pgxpool.Pool
+}
+
+func NewWebhook(dbPool *pgxpool.Pool) *Webhook {
+	return &Webhook{
+		dbPool: dbPool,
+	}
+}
+
+// Create registers a webhook with a fresh signing secret, the only time the secret
+// is returned.
+func (w *Webhook) Create(ctx context.Context, webhook models.Webhook) (models.Webhook, error) {
+	secret, err := newRawToken()
+	if err != nil {
+		return models.Webhook{}, fmt.Errorf("failed generate webhook secret: %v", err)
+	}
+
+	conn, err := w.dbPool.Acquire(ctx)
+	if err != nil {
+		return models.Webhook{}, fmt.Errorf("failed acquire connection from db pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	if webhook.Events == nil {
+		webhook.Events = []string{}
+	}
+
+	webhook.Secret = secret
+
+	err = conn.QueryRow(ctx, `INSERT INTO webhooks (user_id, url, secret, events) VALUES ($1, $2, $3, $4) RETURNING id, created_at`,
+		webhook.UserId, webhook.Url, webhook.Secret, webhook.Events,
+	).Scan(&webhook.Id, &webhook.CreatedAt)
+	if err != nil {
+		return models.Webhook{}, fmt.Errorf("failed insert webhook: %v", err)
+	}
+
+	return webhook, nil
+}
+
+// FindAll lists the webhooks of a user without their secrets.
+func (w *Webhook) FindAll(ctx context.Context, userId int) ([]models.Webhook, error) {
+	conn, err := w.dbPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed acquire connection from db pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	rows, err := conn.Query(ctx, `SELECT id, user_id, url, events, created_at FROM webhooks WHERE user_id = $1 ORDER BY id`, userId)
+	if err != nil {
+		return nil, fmt.Errorf("failed get webhooks: %v", err)
+	}
+
+	defer rows.Close()
+
+	webhooks := []models.Webhook{}
+
+	for rows.Next() {
+		var webhook models.Webhook
+		if err := rows.Scan(&webhook.Id, &webhook.UserId, &webhook.Url, &webhook.Events, &webhook.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed scan webhooks: %v", err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, nil
+}
+
+// Delete removes a webhook of a user along with its delivery log.
+func (w *Webhook) Delete(ctx context.Context, userId, id int) error {
+	conn, err := w.dbPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed acquire connection from db pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tag, err := conn.Exec(ctx, `DELETE FROM webhooks WHERE id = $1 AND user_id = $2`, id, userId)
+	if err != nil {
+		return fmt.Errorf("failed delete webhook: %v", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRow
+	}
+
+	return nil
+}
+
+// FindDeliveries lists the deliveries of a webhook owned by userId, newest first.
+func (w *Webhook) FindDeliveries(ctx context.Context, userId, webhookId int, status string, limit, offset int) ([]models.WebhookDelivery, error) {
+	conn, err := w.dbPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed acquire connection from db pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	var owned bool
+	err = conn.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM webhooks WHERE id = $1 AND user_id = $2)`, webhookId, userId).Scan(&owned)
+	if err != nil {
+		return nil, fmt.Errorf("failed get webhook: %v", err)
+	}
+
+	if !owned {
+		return nil, ErrNoRow
+	}
+
+	q := &queryBuilder{}
+	q.Where("webhook_id = " + q.Arg(webhookId))
+
+	if status != "" {
+		q.Where("status = " + q.Arg(status))
+	}
+
+	sql := `SELECT id, webhook_id, event, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, delivered_at FROM webhook_deliveries` + q.WhereSQL() +
+		" ORDER BY created_at DESC, id DESC LIMIT " + q.Arg(limit) + " OFFSET " + q.Arg(offset)
+
+	rows, err := conn.Query(ctx, sql, q.Args()...)
+	if err != nil {
+		return nil, fmt.Errorf("failed get webhook deliveries: %v", err)
+	}
+
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		err := rows.Scan(&delivery.Id, &delivery.WebhookId, &delivery.Event, &delivery.Payload, &delivery.Status, &delivery.Attempts,
+			&delivery.NextAttemptAt, &delivery.LastStatusCode, &delivery.LastError, &delivery.CreatedAt, &delivery.DeliveredAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed scan webhook deliveries: %v", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, nil
+}
+
+// ClaimDue picks up to limit pending deliveries that are due, with the url and
+// secret of their webhook. Claimed deliveries are pushed back by lease so that no
+// other worker sends them while they are in flight.
+func (w *Webhook) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	conn, err := w.dbPool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed acquire connection from db pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	rows, err := conn.Query(ctx, `
+		UPDATE webhook_deliveries d SET next_attempt_at = now() + make_interval(secs => $2)
+		FROM webhooks w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= now()
+			ORDER BY next_attempt_at, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING d.id, d.webhook_id, d.event, d.payload, d.status, d.attempts, d.created_at, w.url, w.secret
+	`, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed claim webhook deliveries: %v", err)
+	}
+
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		err := rows.Scan(&delivery.Id, &delivery.WebhookId, &delivery.Event, &delivery.Payload, &delivery.Status, &delivery.Attempts,
+			&delivery.CreatedAt, &delivery.Url, &delivery.Secret,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed scan webhook deliveries: %v", err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// MarkDelivered records a successful attempt of a delivery.
+func (w *Webhook) MarkDelivered(ctx context.Context, id, statusCode int) error {
+	conn, err := w.dbPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed acquire connection from db pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = 'delivered', attempts = attempts + 1, last_status_code = $2, last_error = NULL, delivered_at = now()
+		WHERE id = $1
+	`, id, statusCode)
+	if err != nil {
+		return fmt.Errorf("failed mark webhook delivery delivered: %v", err)
+	}
+
+	return nil
+}
+
+// MarkAttemptFailed records a failed attempt of a delivery. The delivery is tried
+// again after retryIn, or given up on when retryIn is nil. statusCode is nil when
+// no response came back.
+func (w *Webhook) MarkAttemptFailed(ctx context.Context, id int, statusCode *int, reason string, retryIn *time.Duration) error {
+	conn, err := w.dbPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed acquire connection from db pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	status := models.WebhookDeliveryFailed
+	var retrySecs float64
+	if retryIn != nil {
+		status = models.WebhookDeliveryPending
+		retrySecs = retryIn.Seconds()
+	}
+
+	_, err = conn.Exec(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = attempts + 1, last_status_code = $3, last_error = $4, next_attempt_at = now() + make_interval(secs => $5)
+		WHERE id = $1
+	`, id, status, statusCode, reason, retrySecs)
+	if err != nil {
+		return fmt.Errorf("failed record webhook delivery attempt: %v", err)
+	}
+
+	return nil
+}
+
+// Retry queues a failed delivery of a webhook owned by userId again, with a fresh
+// set of attempts.
+func (w *Webhook) Retry(ctx context.Context, userId, webhookId, deliveryId int) error {
+	conn, err := w.dbPool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed acquire connection from db pool: %v", err)
+	}
+
+	defer conn.Release()
+
+	tag, err := conn.Exec(ctx, `
+		UPDATE webhook_deliveries d SET status = 'pending', attempts = 0, next_attempt_at = now()
+		FROM webhooks w
+		WHERE w.id = d.webhook_id AND d.id = $1 AND d.webhook_id = $2 AND w.user_id = $3 AND d.status = 'failed'
+	`, deliveryId, webhookId, userId)
+	if err != nil {
+		return fmt.Errorf("failed retry webhook delivery: %v", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRow
+	}
+
+	return nil
+}
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS races;
\ No newline at end of file
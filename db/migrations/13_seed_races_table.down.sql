@@ -0,0 +1 @@
+DELETE FROM races WHERE name IN ('Persian', 'Maine Coon', 'Siamese', 'Ragdoll', 'Bengal', 'Sphynx', 'British Shorthair', 'Abyssinian', 'Scottish Fold', 'Birman');
\ No newline at end of file
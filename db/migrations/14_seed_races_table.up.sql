@@ -0,0 +1,12 @@
+INSERT INTO races (name, size, coat) VALUES
+    ('Persian', 'medium', 'long'),
+    ('Maine Coon', 'large', 'long'),
+    ('Siamese', 'medium', 'short'),
+    ('Ragdoll', 'large', 'long'),
+    ('Bengal', 'medium', 'short'),
+    ('Sphynx', 'medium', 'hairless'),
+    ('British Shorthair', 'medium', 'short'),
+    ('Abyssinian', 'medium', 'short'),
+    ('Scottish Fold', 'medium', 'short'),
+    ('Birman', 'medium', 'long')
+ON CONFLICT (name) DO NOTHING;
\ No newline at end of file
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_cats_search_vector;
+DROP INDEX IF EXISTS idx_cats_name_trgm;
+
+ALTER TABLE cats DROP COLUMN IF EXISTS search_vector;
\ No newline at end of file
@@ -0,0 +1,9 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE cats ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', coalesce(name, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(description, '')), 'B')
+) STORED;
+
+CREATE INDEX idx_cats_search_vector ON cats USING GIN(search_vector);
+CREATE INDEX idx_cats_name_trgm ON cats USING GIN(name gin_trgm_ops);
\ No newline at end of file
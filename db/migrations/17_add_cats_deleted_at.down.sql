@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_cats_active_created_at_id;
+
+ALTER TABLE cats DROP COLUMN IF EXISTS deleted_at;
\ No newline at end of file
@@ -0,0 +1,3 @@
+ALTER TABLE cats ADD COLUMN deleted_at TIMESTAMP;
+
+CREATE INDEX idx_cats_active_created_at_id ON cats(created_at DESC, id DESC) WHERE deleted_at IS NULL;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE matches DROP COLUMN IF EXISTS match_cat_snapshot;
+ALTER TABLE matches DROP COLUMN IF EXISTS user_cat_snapshot;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE matches ADD COLUMN match_cat_snapshot JSONB;
+ALTER TABLE matches ADD COLUMN user_cat_snapshot JSONB;
\ No newline at end of file
@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_matches_status_pending;
+CREATE INDEX idx_matches_status_removed ON matches(status) WHERE status = 'removed';
+
+ALTER TABLE matches DROP CONSTRAINT IF EXISTS matches_status_check;
+ALTER TABLE matches ALTER COLUMN status DROP NOT NULL;
+ALTER TABLE matches ALTER COLUMN status DROP DEFAULT;
+
+UPDATE matches SET status = 'removed' WHERE status IN ('rejected', 'withdrawn', 'superseded');
+UPDATE matches SET status = '' WHERE status = 'pending';
+
+DROP TABLE IF EXISTS match_events;
\ No newline at end of file
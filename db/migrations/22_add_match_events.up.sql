@@ -0,0 +1,22 @@
+CREATE TABLE match_events (
+    id SERIAL PRIMARY KEY,
+    match_id INT NOT NULL REFERENCES matches(id) ON DELETE CASCADE,
+    actor_user_id INT REFERENCES users(id) ON DELETE SET NULL,
+    from_status VARCHAR(50),
+    to_status VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_match_events_match_id ON match_events(match_id, created_at);
+
+-- legacy matches used an empty status for pending requests and 'removed' for
+-- every closed one, we cannot tell why they were closed so they count as superseded
+UPDATE matches SET status = 'pending' WHERE status IS NULL OR status = '';
+UPDATE matches SET status = 'superseded' WHERE status = 'removed';
+
+ALTER TABLE matches ALTER COLUMN status SET DEFAULT 'pending';
+ALTER TABLE matches ALTER COLUMN status SET NOT NULL;
+ALTER TABLE matches ADD CONSTRAINT matches_status_check CHECK (status IN ('pending', 'approved', 'rejected', 'withdrawn', 'superseded'));
+
+DROP INDEX IF EXISTS idx_matches_status_removed;
+CREATE INDEX idx_matches_status_pending ON matches(status) WHERE status = 'pending';
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_matches_user_id_created_at_id;
+DROP INDEX IF EXISTS idx_matches_match_user_id_created_at_id;
\ No newline at end of file
@@ -0,0 +1,2 @@
+CREATE INDEX idx_matches_user_id_created_at_id ON matches(user_id, created_at DESC, id DESC);
+CREATE INDEX idx_matches_match_user_id_created_at_id ON matches(match_user_id, created_at DESC, id DESC);
\ No newline at end of file
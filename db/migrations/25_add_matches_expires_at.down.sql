@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_matches_pending_expires_at;
+
+UPDATE matches SET status = 'superseded' WHERE status = 'expired';
+
+ALTER TABLE matches DROP CONSTRAINT IF EXISTS matches_status_check;
+ALTER TABLE matches ADD CONSTRAINT matches_status_check CHECK (status IN ('pending', 'approved', 'rejected', 'withdrawn', 'superseded'));
+
+ALTER TABLE matches DROP COLUMN IF EXISTS expires_at;
\ No newline at end of file
@@ -0,0 +1,9 @@
+ALTER TABLE matches ADD COLUMN expires_at TIMESTAMP;
+
+-- pending requests sent before expiry existed get the default TTL of 7 days
+UPDATE matches SET expires_at = created_at + INTERVAL '7 days' WHERE status = 'pending';
+
+ALTER TABLE matches DROP CONSTRAINT IF EXISTS matches_status_check;
+ALTER TABLE matches ADD CONSTRAINT matches_status_check CHECK (status IN ('pending', 'approved', 'rejected', 'withdrawn', 'superseded', 'expired'));
+
+CREATE INDEX idx_matches_pending_expires_at ON matches(expires_at) WHERE status = 'pending';
\ No newline at end of file
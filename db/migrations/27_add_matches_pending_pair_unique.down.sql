@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_matches_pending_pair;
\ No newline at end of file
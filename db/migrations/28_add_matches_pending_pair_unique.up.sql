@@ -0,0 +1,20 @@
+-- keep only the latest pending request between two cats, whichever way it goes
+WITH duplicates AS (
+    UPDATE matches SET status = 'superseded', updated_at = now()
+    WHERE id IN (
+        SELECT id FROM (
+            SELECT id, ROW_NUMBER() OVER (
+                PARTITION BY LEAST(user_cat_id, match_cat_id), GREATEST(user_cat_id, match_cat_id)
+                ORDER BY created_at DESC, id DESC
+            ) AS rank
+            FROM matches
+            WHERE status = 'pending'
+        ) pending
+        WHERE rank > 1
+    )
+    RETURNING id
+)
+INSERT INTO match_events (match_id, actor_user_id, from_status, to_status)
+SELECT id, NULL, 'pending', 'superseded' FROM duplicates;
+
+CREATE UNIQUE INDEX idx_matches_pending_pair ON matches(LEAST(user_cat_id, match_cat_id), GREATEST(user_cat_id, match_cat_id)) WHERE status = 'pending';
\ No newline at end of file
@@ -0,0 +1,6 @@
+ALTER TABLE users ADD COLUMN last_active_at TIMESTAMP;
+
+UPDATE users SET last_active_at = COALESCE((SELECT MAX(created_at) FROM refresh_tokens WHERE refresh_tokens.user_id = users.id), created_at);
+
+ALTER TABLE users ALTER COLUMN last_active_at SET DEFAULT CURRENT_TIMESTAMP;
+ALTER TABLE users ALTER COLUMN last_active_at SET NOT NULL;
\ No newline at end of file
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS reports;
+DROP TABLE IF EXISTS user_blocks;
\ No newline at end of file
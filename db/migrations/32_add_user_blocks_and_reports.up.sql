@@ -0,0 +1,21 @@
+CREATE TABLE user_blocks (
+    blocker_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    blocked_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (blocker_id, blocked_id)
+);
+
+CREATE INDEX idx_user_blocks_blocked_id ON user_blocks(blocked_id);
+
+CREATE TABLE reports (
+    id SERIAL PRIMARY KEY,
+    reporter_id INT REFERENCES users(id) ON DELETE SET NULL,
+    target_type VARCHAR(10) NOT NULL CHECK (target_type IN ('user', 'cat', 'match')),
+    target_id INT NOT NULL,
+    reason TEXT NOT NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'open' CHECK (status IN ('open', 'resolved', 'dismissed')),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    resolved_at TIMESTAMP
+);
+
+CREATE INDEX idx_reports_open ON reports(created_at) WHERE status = 'open';
\ No newline at end of file
@@ -0,0 +1,18 @@
+CREATE FUNCTION notify_notification() RETURNS TRIGGER AS $$
+BEGIN
+    -- created_at holds the session local time, convert it before stamping it as UTC
+    PERFORM pg_notify('notifications', json_build_object(
+        'id', NEW.id,
+        'userId', NEW.user_id,
+        'type', NEW.type,
+        'matchId', NEW.match_id,
+        'actorUserId', NEW.actor_user_id,
+        'readAt', NEW.read_at,
+        'createdAt', to_char(NEW.created_at AT TIME ZONE current_setting('TimeZone') AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')
+    )::text);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER notifications_notify AFTER INSERT ON notifications
+FOR EACH ROW EXECUTE FUNCTION notify_notification();
\ No newline at end of file
@@ -0,0 +1,5 @@
+DROP TRIGGER IF EXISTS notifications_enqueue_webhooks ON notifications;
+DROP FUNCTION IF EXISTS enqueue_webhook_deliveries();
+
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
\ No newline at end of file
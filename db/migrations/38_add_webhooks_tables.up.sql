@@ -0,0 +1,49 @@
+CREATE TABLE webhooks (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    secret VARCHAR(64) NOT NULL,
+    events TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_webhooks_user_id ON webhooks(user_id);
+
+-- the outbox of the webhooks, rows stay after delivery as the delivery log
+CREATE TABLE webhook_deliveries (
+    id SERIAL PRIMARY KEY,
+    webhook_id INT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    event VARCHAR(50) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'delivered', 'failed')),
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_status_code INT,
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMP
+);
+
+CREATE INDEX idx_webhook_deliveries_webhook_id ON webhook_deliveries(webhook_id, created_at DESC, id DESC);
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+
+-- deliveries are queued in the transaction that creates the notification
+CREATE FUNCTION enqueue_webhook_deliveries() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO webhook_deliveries (webhook_id, event, payload)
+    SELECT w.id, NEW.type, json_build_object(
+        'id', NEW.id,
+        'userId', NEW.user_id,
+        'type', NEW.type,
+        'matchId', NEW.match_id,
+        'actorUserId', NEW.actor_user_id,
+        'createdAt', to_char(NEW.created_at AT TIME ZONE current_setting('TimeZone') AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')
+    )
+    FROM webhooks w
+    WHERE w.user_id = NEW.user_id AND (cardinality(w.events) = 0 OR NEW.type = ANY(w.events));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER notifications_enqueue_webhooks AFTER INSERT ON notifications
+FOR EACH ROW EXECUTE FUNCTION enqueue_webhook_deliveries();
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN email_notifications BOOLEAN NOT NULL DEFAULT TRUE;
\ No newline at end of file
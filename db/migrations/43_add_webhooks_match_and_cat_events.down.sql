@@ -0,0 +1,25 @@
+DROP TRIGGER IF EXISTS cats_enqueue_webhooks ON cats;
+DROP FUNCTION IF EXISTS enqueue_cat_webhooks();
+DROP TRIGGER IF EXISTS match_events_enqueue_webhooks ON match_events;
+DROP FUNCTION IF EXISTS enqueue_match_webhooks();
+DROP FUNCTION IF EXISTS enqueue_webhook_event(INT[], TEXT, JSON);
+
+CREATE FUNCTION enqueue_webhook_deliveries() RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO webhook_deliveries (webhook_id, event, payload)
+    SELECT w.id, NEW.type, json_build_object(
+        'id', NEW.id,
+        'userId', NEW.user_id,
+        'type', NEW.type,
+        'matchId', NEW.match_id,
+        'actorUserId', NEW.actor_user_id,
+        'createdAt', to_char(NEW.created_at AT TIME ZONE current_setting('TimeZone') AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')
+    )
+    FROM webhooks w
+    WHERE w.user_id = NEW.user_id AND (cardinality(w.events) = 0 OR NEW.type = ANY(w.events));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER notifications_enqueue_webhooks AFTER INSERT ON notifications
+FOR EACH ROW EXECUTE FUNCTION enqueue_webhook_deliveries();
\ No newline at end of file
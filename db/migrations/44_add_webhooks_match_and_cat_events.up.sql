@@ -0,0 +1,81 @@
+-- webhooks get their own events instead of copies of the notifications, so that
+-- both owners hear about a match and owners hear about their cats
+DROP TRIGGER IF EXISTS notifications_enqueue_webhooks ON notifications;
+DROP FUNCTION IF EXISTS enqueue_webhook_deliveries();
+
+CREATE FUNCTION enqueue_webhook_event(recipients INT[], event_name TEXT, body JSON) RETURNS VOID AS $$
+BEGIN
+    INSERT INTO webhook_deliveries (webhook_id, event, payload)
+    SELECT w.id, event_name, body
+    FROM webhooks w
+    WHERE w.user_id = ANY(recipients) AND (cardinality(w.events) = 0 OR event_name = ANY(w.events));
+END;
+$$ LANGUAGE plpgsql;
+
+-- every status change of a match goes through match_events, whatever code path made it
+CREATE FUNCTION enqueue_match_webhooks() RETURNS TRIGGER AS $$
+DECLARE
+    m matches%ROWTYPE;
+    event_name TEXT;
+BEGIN
+    SELECT * INTO m FROM matches WHERE id = NEW.match_id;
+
+    event_name := CASE
+        WHEN NEW.to_status = 'pending' AND NEW.from_status IS NULL THEN 'match_requested'
+        WHEN NEW.to_status = 'pending' THEN 'match_reissued'
+        ELSE 'match_' || NEW.to_status
+    END;
+
+    PERFORM enqueue_webhook_event(ARRAY[m.user_id, m.match_user_id], event_name, json_build_object(
+        'event', event_name,
+        'matchId', m.id,
+        'status', NEW.to_status,
+        'previousStatus', NEW.from_status,
+        'issuerUserId', m.user_id,
+        'receiverUserId', m.match_user_id,
+        'userCatId', m.user_cat_id,
+        'matchCatId', m.match_cat_id,
+        'actorUserId', NEW.actor_user_id,
+        'createdAt', to_char(NEW.created_at AT TIME ZONE current_setting('TimeZone') AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')
+    ));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER match_events_enqueue_webhooks AFTER INSERT ON match_events
+FOR EACH ROW EXECUTE FUNCTION enqueue_match_webhooks();
+
+CREATE FUNCTION enqueue_cat_webhooks() RETURNS TRIGGER AS $$
+DECLARE
+    event_name TEXT;
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        event_name := 'cat_created';
+    ELSIF OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL THEN
+        event_name := 'cat_deleted';
+    ELSIF OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL THEN
+        event_name := 'cat_restored';
+    ELSIF (OLD.name, OLD.race, OLD.sex, OLD.age_in_month, OLD.description, OLD.image_urls, OLD.has_matched)
+        IS DISTINCT FROM (NEW.name, NEW.race, NEW.sex, NEW.age_in_month, NEW.description, NEW.image_urls, NEW.has_matched) THEN
+        event_name := 'cat_updated';
+    ELSE
+        RETURN NEW;
+    END IF;
+
+    PERFORM enqueue_webhook_event(ARRAY[NEW.user_id], event_name, json_build_object(
+        'event', event_name,
+        'catId', NEW.id,
+        'userId', NEW.user_id,
+        'name', NEW.name,
+        'race', NEW.race,
+        'sex', NEW.sex,
+        'ageInMonth', NEW.age_in_month,
+        'hasMatched', NEW.has_matched,
+        'createdAt', to_char(now() AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')
+    ));
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER cats_enqueue_webhooks AFTER INSERT OR UPDATE ON cats
+FOR EACH ROW EXECUTE FUNCTION enqueue_cat_webhooks();
\ No newline at end of file
@@ -0,0 +1 @@
+CREATE INDEX idx_cats_created_at_id ON cats(created_at DESC, id DESC);
\ No newline at end of file
@@ -4,19 +4,32 @@ import (
 	"time"
 )
 
+// SearchModeFullText ranks cats by relevance of their name and description and
+// tolerates typos in the name, the default mode is a plain substring match on the name.
+const SearchModeFullText = "fulltext"
+
 type (
 	Cat struct {
-		Id          int       `json:"id"`
-		UserId      int       `json:"user_id"`
-		Name        string    `json:"name"`
-		Race        string    `json:"race"`
-		Sex         string    `json:"sex"`
-		AgeInMonth  int       `json:"ageInMonth"`
-		Description string    `json:"description"`
-		ImageUrls   []string  `json:"imageUrls"`
-		HasMatched  bool      `json:"hasMatched"`
-		CreatedAt   time.Time `json:"createdAt"`
-		UpdatedAt   time.Time `json:"updatedAt"`
+		Id          int        `json:"id"`
+		UserId      int        `json:"user_id"`
+		Name        string     `json:"name"`
+		Race        string     `json:"race"`
+		Sex         string     `json:"sex"`
+		AgeInMonth  int        `json:"ageInMonth"`
+		Description string     `json:"description"`
+		ImageUrls   []string   `json:"imageUrls"`
+		HasMatched  bool       `json:"hasMatched"`
+		CreatedAt   time.Time  `json:"createdAt"`
+		UpdatedAt   time.Time  `json:"updatedAt"`
+		DeletedAt   *time.Time `json:"deletedAt"`
+		// Highlight is only set by a full text search.
+		Highlight *CatHighlight `json:"highlight,omitempty"`
+	}
+
+	CatHighlight struct {
+		Rank        float64 `json:"rank"`
+		Name        string  `json:"name"`
+		Description string  `json:"description"`
 	}
 
 	FilterGetCats struct {
@@ -25,10 +38,18 @@ type (
 		Offset             int    `json:"offset"`
 		Race               string `json:"Race"`
 		Sex                string `json:"sex"`
-		HasMatched         bool   `json:"hasMatched"`
+		HasMatched         *bool  `json:"hasMatched"`
 		AgeInMonthOperator string `json:"ageInMonthOperator"`
 		AgeInMonthValue    int    `json:"ageInMonthValue"`
 		Owned              bool   `json:"owned"`
 		Search             string `json:"search"`
+		SearchMode         string `json:"searchMode"`
+		Cursor             *Cursor
+	}
+
+	// Cursor points at the last row of a page ordered by created_at, id.
+	Cursor struct {
+		CreatedAt time.Time
+		Id        int
 	}
 )
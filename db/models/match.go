@@ -2,14 +2,64 @@ package models
 
 import "time"
 
-type Match struct {
-	Id          int       `json:"matchId"`
-	UserId      int       `json:"userId"`
-	MatchUserId int       `json:"matchUserId"`
-	MatchCatId  int       `json:"matchCatId"`
-	UserCatId   int       `json:"userCatId"`
-	Message     string    `json:"message"`
-	Status      string    `json:"status"`
-	CreatedAt   time.Time `json:"createdAt"`
-	UpdatedAt   time.Time `json:"updatedAt"`
-}
+// Match directions, seen from the user listing the matches.
+const (
+	MatchDirectionIncoming = "incoming"
+	MatchDirectionOutgoing = "outgoing"
+)
+
+type (
+	Match struct {
+		Id          int       `json:"matchId"`
+		UserId      int       `json:"userId"`
+		MatchUserId int       `json:"matchUserId"`
+		MatchCatId  int       `json:"matchCatId"`
+		UserCatId   int       `json:"userCatId"`
+		Message     string    `json:"message"`
+		Status      string    `json:"status"`
+		CreatedAt   time.Time `json:"createdAt"`
+		UpdatedAt   time.Time `json:"updatedAt"`
+		// ExpiresAt is when a pending match expires, nil for legacy matches.
+		ExpiresAt *time.Time `json:"expiresAt"`
+		// The snapshots hold both cats as they were when the request was sent,
+		// they are nil for matches created before snapshots existed.
+		MatchCatSnapshot *CatSnapshot `json:"matchCatSnapshot"`
+		UserCatSnapshot  *CatSnapshot `json:"userCatSnapshot"`
+	}
+
+	// MatchEvent is one status change of a match. FromStatus is nil for the
+	// creation event and ActorUserId is nil once the actor deleted their account.
+	MatchEvent struct {
+		Id          int       `json:"id"`
+		MatchId     int       `json:"matchId"`
+		ActorUserId *int      `json:"actorUserId"`
+		FromStatus  *string   `json:"fromStatus"`
+		ToStatus    string    `json:"toStatus"`
+		CreatedAt   time.Time `json:"createdAt"`
+	}
+
+	CatSnapshot struct {
+		Id          int       `json:"id"`
+		Name        string    `json:"name"`
+		Race        string    `json:"race"`
+		Sex         string    `json:"sex"`
+		AgeInMonth  int       `json:"ageInMonth"`
+		Description string    `json:"description"`
+		ImageUrls   []string  `json:"imageUrls"`
+		HasMatched  bool      `json:"hasMatched"`
+		CreatedAt   time.Time `json:"createdAt"`
+	}
+
+	FilterGetMatches struct {
+		// Direction is empty for both incoming and outgoing matches.
+		Direction string
+		// Statuses defaults to the pending and approved matches when empty.
+		Statuses    []string
+		CatId       int
+		CreatedFrom *time.Time
+		CreatedTo   *time.Time
+		Limit       int
+		Offset      int
+		Cursor      *Cursor
+	}
+)
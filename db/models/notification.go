@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Notification types, each one tells a user what happened to one of their matches.
+const (
+	NotificationMatchRequested  = "match_requested"
+	NotificationMatchApproved   = "match_approved"
+	NotificationMatchRejected   = "match_rejected"
+	NotificationMatchWithdrawn  = "match_withdrawn"
+	NotificationMatchSuperseded = "match_superseded"
+	NotificationMatchExpired    = "match_expired"
+)
+
+type Notification struct {
+	Id          int        `json:"id"`
+	UserId      int        `json:"userId"`
+	Type        string     `json:"type"`
+	MatchId     *int       `json:"matchId"`
+	ActorUserId *int       `json:"actorUserId"`
+	ReadAt      *time.Time `json:"readAt"`
+	CreatedAt   time.Time  `json:"createdAt"`
+}
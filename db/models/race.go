@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+type Race struct {
+	Id        int       `json:"id"`
+	Name      string    `json:"name"`
+	Size      string    `json:"size"`
+	Coat      string    `json:"coat"`
+	CreatedAt time.Time `json:"createdAt"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Report targets, a report points at a user, a cat or a match by id.
+const (
+	ReportTargetUser  = "user"
+	ReportTargetCat   = "cat"
+	ReportTargetMatch = "match"
+)
+
+type Report struct {
+	Id         int        `json:"id"`
+	ReporterId *int       `json:"reporterId"`
+	TargetType string     `json:"targetType"`
+	TargetId   int        `json:"targetId"`
+	Reason     string     `json:"reason"`
+	Status     string     `json:"status"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ResolvedAt *time.Time `json:"resolvedAt"`
+}
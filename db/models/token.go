@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+type RefreshToken struct {
+	Id        int        `json:"id"`
+	UserId    int        `json:"userId"`
+	FamilyId  string     `json:"familyId"`
+	TokenHash string     `json:"-"`
+	ExpiresAt time.Time  `json:"expiresAt"`
+	RevokedAt *time.Time `json:"revokedAt"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
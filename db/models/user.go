@@ -7,5 +7,10 @@ type User struct {
 	Email     string    `json:"email"`
 	Name      string    `json:"name"`
 	Password  string    `json:"password,omitempty"`
+	IsAdmin   bool      `json:"isAdmin"`
 	CreatedAt time.Time `json:"createdAt"`
+	// EmailNotifications tells whether the user wants match updates by email.
+	EmailNotifications bool `json:"emailNotifications"`
+	// EmailVerifiedAt is nil until the user verifies their current email.
+	EmailVerifiedAt *time.Time `json:"emailVerifiedAt"`
 }
@@ -0,0 +1,58 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Webhook delivery statuses.
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// Webhook events. Match events go to both owners, cat events to the owner of the cat.
+const (
+	WebhookEventMatchRequested  = "match_requested"
+	WebhookEventMatchReissued   = "match_reissued"
+	WebhookEventMatchApproved   = "match_approved"
+	WebhookEventMatchRejected   = "match_rejected"
+	WebhookEventMatchWithdrawn  = "match_withdrawn"
+	WebhookEventMatchSuperseded = "match_superseded"
+	WebhookEventMatchExpired    = "match_expired"
+	WebhookEventCatCreated      = "cat_created"
+	WebhookEventCatUpdated      = "cat_updated"
+	WebhookEventCatDeleted      = "cat_deleted"
+	WebhookEventCatRestored     = "cat_restored"
+)
+
+type (
+	Webhook struct {
+		Id     int    `json:"id"`
+		UserId int    `json:"userId"`
+		Url    string `json:"url"`
+		// Secret signs the deliveries, it is only shown when the webhook is created.
+		Secret string `json:"secret,omitempty"`
+		// Events lists the events sent to the webhook, every event when empty.
+		Events    []string  `json:"events"`
+		CreatedAt time.Time `json:"createdAt"`
+	}
+
+	WebhookDelivery struct {
+		Id             int             `json:"id"`
+		WebhookId      int             `json:"webhookId"`
+		Event          string          `json:"event"`
+		Payload        json.RawMessage `json:"payload"`
+		Status         string          `json:"status"`
+		Attempts       int             `json:"attempts"`
+		NextAttemptAt  time.Time       `json:"nextAttemptAt"`
+		LastStatusCode *int            `json:"lastStatusCode"`
+		LastError      *string         `json:"lastError"`
+		CreatedAt      time.Time       `json:"createdAt"`
+		DeliveredAt    *time.Time      `json:"deliveredAt"`
+		// Url and Secret of the webhook, only loaded to send the delivery.
+		Url    string `json:"-"`
+		Secret string `json:"-"`
+	}
+)
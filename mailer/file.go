@@ -0,0 +1,66 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+)
+
+// File writes every email as an .eml file instead of sending it, handy to test
+// the emails locally.
+type File struct {
+	dir  string
+	from string
+}
+
+func NewFile(dir, from string) (*File, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed create mail dir: %v", err)
+	}
+
+	return &File{
+		dir:  dir,
+		from: from,
+	}, nil
+}
+
+func (f *File) Send(ctx context.Context, msg Message) error {
+	raw, err := build(f.from, msg)
+	if err != nil {
+		return fmt.Errorf("failed build mail: %v", err)
+	}
+
+	// the timestamp keeps the files in sending order, the random suffix keeps
+	// concurrent sends from overwriting each other
+	file, err := os.CreateTemp(f.dir, strconv.FormatInt(time.Now().UnixNano(), 10)+"-*.eml")
+	if err != nil {
+		return fmt.Errorf("failed create mail file: %v", err)
+	}
+
+	if _, err := file.Write(raw); err != nil {
+		file.Close()
+		return fmt.Errorf("failed write mail: %v", err)
+	}
+
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed write mail: %v", err)
+	}
+
+	return nil
+}
+
+// Log only logs the recipient and subject of every email. The body is left out, it
+// can hold verification and password reset tokens.
+type Log struct{}
+
+func NewLog() *Log {
+	return &Log{}
+}
+
+func (l *Log) Send(ctx context.Context, msg Message) error {
+	log.Printf("mail to %s: %s", msg.To, msg.Subject)
+	return nil
+}
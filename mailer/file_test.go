@@ -0,0 +1,41 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestFileKeepsConcurrentMails(t *testing.T) {
+	const sends = 50
+
+	dir := t.TempDir()
+	file, err := NewFile(dir, "cats@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < sends; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			msg := Message{To: fmt.Sprintf("user%d@example.com", i), Subject: "hi", Text: "hello"}
+			if err := file.Send(context.Background(), msg); err != nil {
+				t.Errorf("Send() = %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != sends {
+		t.Errorf("wrote %d mail files, want %d", len(entries), sends)
+	}
+}
@@ -0,0 +1,94 @@
+// Package mailer sends emails to users through a configurable driver.
+package mailer
+
+import (
+	"CatsSocial/configs"
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// Message is one email, it is sent with both its text and HTML bodies.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers emails.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// New returns the mailer of the configured driver, nil when emails are disabled.
+func New(config configs.Config) (Mailer, error) {
+	switch config.MailDriver {
+	case "none":
+		return nil, nil
+	case "log":
+		return NewLog(), nil
+	case "file":
+		return NewFile(config.MailFileDir, config.MailFrom)
+	case "smtp":
+		return NewSMTP(config.SMTPHost, config.SMTPPort, config.SMTPUsername, config.SMTPPassword, config.MailFrom)
+	default:
+		return nil, fmt.Errorf("unsupported mail driver %q", config.MailDriver)
+	}
+}
+
+// headerValue keeps user provided values from adding headers of their own.
+func headerValue(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
+
+// build renders msg as a multipart/alternative MIME message.
+func build(from string, msg Message) ([]byte, error) {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	var raw bytes.Buffer
+	fmt.Fprintf(&raw, "From: %s\r\n", headerValue(from))
+	fmt.Fprintf(&raw, "To: %s\r\n", headerValue(msg.To))
+	fmt.Fprintf(&raw, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", headerValue(msg.Subject)))
+	fmt.Fprintf(&raw, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&raw, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&raw, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", parts.Boundary())
+	raw.Write(body.Bytes())
+
+	return raw.Bytes(), nil
+}
@@ -0,0 +1,57 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+)
+
+// SMTP sends emails through an SMTP server, upgrading to TLS when the server offers it.
+type SMTP struct {
+	addr string
+	auth smtp.Auth
+	from string
+	// sender is the bare address of from, used as the envelope sender
+	sender string
+}
+
+func NewSMTP(host string, port int, username, password, from string) (*SMTP, error) {
+	if host == "" {
+		return nil, fmt.Errorf("smtp host is required")
+	}
+
+	address, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("failed parse mail from address: %v", err)
+	}
+
+	var auth smtp.Auth
+	if username != "" {
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+
+	return &SMTP{
+		addr:   net.JoinHostPort(host, strconv.Itoa(port)),
+		auth:   auth,
+		from:   from,
+		sender: address.Address,
+	}, nil
+}
+
+// Send ignores ctx, net/smtp has no way to cancel a send. Callers send in the
+// background so a slow server only holds up a goroutine.
+func (s *SMTP) Send(ctx context.Context, msg Message) error {
+	raw, err := build(s.from, msg)
+	if err != nil {
+		return fmt.Errorf("failed build mail: %v", err)
+	}
+
+	if err := smtp.SendMail(s.addr, s.auth, s.sender, []string{msg.To}, raw); err != nil {
+		return fmt.Errorf("failed send mail: %v", err)
+	}
+
+	return nil
+}
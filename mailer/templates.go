@@ -0,0 +1,63 @@
+package mailer
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"path"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+// Every email has a name.txt template, which also defines its "subject", and a
+// name.html template.
+var (
+	textTemplates = map[string]*texttemplate.Template{}
+	htmlTemplates = map[string]*htmltemplate.Template{}
+)
+
+func init() {
+	files, err := fs.Glob(templateFS, "templates/*.txt")
+	if err != nil {
+		panic(err)
+	}
+
+	for _, file := range files {
+		name := strings.TrimSuffix(path.Base(file), ".txt")
+		textTemplates[name] = texttemplate.Must(texttemplate.ParseFS(templateFS, file))
+		htmlTemplates[name] = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/"+name+".html"))
+	}
+}
+
+// Render builds the email called name from data, the recipient is left to the caller.
+func Render(name string, data any) (Message, error) {
+	text, ok := textTemplates[name]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown mail template %q", name)
+	}
+
+	var subject, textBody, htmlBody bytes.Buffer
+
+	if err := text.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("failed render mail subject: %v", err)
+	}
+
+	if err := text.ExecuteTemplate(&textBody, name+".txt", data); err != nil {
+		return Message{}, fmt.Errorf("failed render mail text: %v", err)
+	}
+
+	if err := htmlTemplates[name].ExecuteTemplate(&htmlBody, name+".html", data); err != nil {
+		return Message{}, fmt.Errorf("failed render mail html: %v", err)
+	}
+
+	return Message{
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    textBody.String(),
+		HTML:    htmlBody.String(),
+	}, nil
+}
@@ -0,0 +1,106 @@
+package mailer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	match := map[string]string{
+		"RecipientName": "Budi",
+		"ActorName":     "Sari",
+		"UserCatName":   "Tom",
+		"MatchCatName":  "Luna",
+		"Message":       "<b>hi</b> & welcome",
+	}
+
+	tests := []struct {
+		name        string
+		template    string
+		data        map[string]string
+		wantSubject string
+		wantText    []string
+		wantHTML    []string
+		notText     []string
+	}{
+		{
+			name:        "match requested",
+			template:    "match_requested",
+			data:        match,
+			wantSubject: "Sari wants to match Tom with Luna",
+			wantText:    []string{"Hi Budi,", "their cat Tom with your cat Luna", `"<b>hi</b> & welcome"`},
+			wantHTML:    []string{"Budi", "&lt;b&gt;hi&lt;/b&gt; &amp; welcome"},
+		},
+		{
+			name:     "match approved",
+			template: "match_approved",
+			data:     match,
+			wantText: []string{"Hi Budi,", "Sari"},
+			wantHTML: []string{"Budi", "Sari"},
+		},
+		{
+			name:        "verify email with a link",
+			template:    "verify_email",
+			data:        map[string]string{"Name": "Budi", "Link": "https://cats.example/verify-email?token=abc", "Token": "abc", "ExpiresIn": "2 days"},
+			wantSubject: "Verify your Cats Social email",
+			wantText:    []string{"Hi Budi,", "https://cats.example/verify-email?token=abc", "2 days"},
+			wantHTML:    []string{`href="https://cats.example/verify-email?token=abc"`},
+			notText:     []string{"verification code"},
+		},
+		{
+			name:     "verify email with a code",
+			template: "verify_email",
+			data:     map[string]string{"Name": "Budi", "Token": "abc", "ExpiresIn": "2 days"},
+			wantText: []string{"Your verification code: abc"},
+		},
+		{
+			name:     "reset password",
+			template: "reset_password",
+			data:     map[string]string{"Name": "Budi", "Link": "https://cats.example/reset-password?token=xyz", "Token": "xyz", "ExpiresIn": "1 hour"},
+			wantText: []string{"Hi Budi,", "https://cats.example/reset-password?token=xyz", "1 hour"},
+			wantHTML: []string{"https://cats.example/reset-password?token=xyz"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := Render(tt.template, tt.data)
+			if err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+
+			if msg.Subject == "" || strings.ContainsAny(msg.Subject, "\r\n") {
+				t.Errorf("Subject = %q, want one non empty line", msg.Subject)
+			}
+			if tt.wantSubject != "" && msg.Subject != tt.wantSubject {
+				t.Errorf("Subject = %q, want %q", msg.Subject, tt.wantSubject)
+			}
+
+			for _, want := range tt.wantText {
+				if !strings.Contains(msg.Text, want) {
+					t.Errorf("Text does not contain %q:\n%s", want, msg.Text)
+				}
+			}
+			for _, unwanted := range tt.notText {
+				if strings.Contains(msg.Text, unwanted) {
+					t.Errorf("Text contains %q:\n%s", unwanted, msg.Text)
+				}
+			}
+			for _, want := range tt.wantHTML {
+				if !strings.Contains(msg.HTML, want) {
+					t.Errorf("HTML does not contain %q:\n%s", want, msg.HTML)
+				}
+			}
+
+			if strings.Contains(msg.Text, "<no value>") || strings.Contains(msg.HTML, "<no value>") {
+				t.Errorf("a template field was left empty:\n%s\n%s", msg.Text, msg.HTML)
+			}
+		})
+	}
+}
+
+func TestRenderUnknownTemplate(t *testing.T) {
+	if _, err := Render("missing", nil); err == nil {
+		t.Error("Render() of an unknown template error = nil, want an error")
+	}
+}
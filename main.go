@@ -7,8 +7,15 @@ import (
 	"CatsSocial/api/handlers"
 	"CatsSocial/api/responses"
 	"CatsSocial/api/routes"
+	"CatsSocial/auth"
 	"CatsSocial/configs"
 	"CatsSocial/db/connections"
+	"CatsSocial/db/functions"
+	"CatsSocial/mailer"
+	"CatsSocial/storage"
+	"CatsSocial/stream"
+	"CatsSocial/webhook"
+	"CatsSocial/workers"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
@@ -24,6 +31,21 @@ func main() {
 		log.Fatal("Cannot load config:", err)
 	}
 
+	authenticator, err := auth.New(config)
+	if err != nil {
+		log.Fatal("Cannot load jwt keys:", err)
+	}
+
+	fileStorage, err := storage.New(config)
+	if err != nil {
+		log.Fatal("Cannot load storage:", err)
+	}
+
+	emailer, err := mailer.New(config)
+	if err != nil {
+		log.Fatal("Cannot load mailer:", err)
+	}
+
 	dbPool, err := connections.NewPgConn(config)
 	if err != nil {
 		log.Fatalf("failed open connection to db: %v", err)
@@ -34,11 +56,24 @@ func main() {
 		log.Fatalf("FAILED PING TO DB: %v", err)
 	}
 
+	hub := stream.NewHub()
+
 	deps := handlers.Dependencies{
-		Cfg:    config,
-		DbPool: dbPool,
+		Cfg:     config,
+		DbPool:  dbPool,
+		Auth:    authenticator,
+		Storage: fileStorage,
+		Hub:     hub,
+		Mailer:  emailer,
 	}
 
+	// background jobs live as long as the server
+	go workers.ExpireMatches(context.Background(), functions.NewMatch(dbPool, config), config.MatchExpiryInterval)
+	go workers.PurgeDeletedCats(context.Background(), functions.NewCatFn(dbPool), fileStorage, config.CatPurgeAfter, config.CatPurgeInterval)
+	go stream.Listen(context.Background(), dbPool, hub)
+	go workers.DeliverWebhooks(context.Background(), functions.NewWebhook(dbPool), webhook.NewSender(config.WebhookTimeout, config.WebhookAllowPrivate),
+		config.WebhookDeliveryInterval, config.WebhookTimeout, config.WebhookRetryBase, config.WebhookMaxAttempts)
+
 	// load Middlewares
 	app.Use(recover.New())
 	app.Use(logger.New())
@@ -0,0 +1,85 @@
+package match
+
+import (
+	"CatsSocial/db/models"
+	"context"
+	"errors"
+)
+
+type (
+	// Pair is a prospective match, UserCat is the cat of the user sending the request.
+	// Reciprocal is set when MatchCat already sent UserCat a pending request, sending
+	// one back approves it rather than opening a new request.
+	Pair struct {
+		UserCat    models.Cat
+		MatchCat   models.Cat
+		Reciprocal bool
+	}
+
+	// Rule decides whether a pair of cats can be matched. Check returns a Violation
+	// when the pair breaks the rule and any other error when it cannot tell.
+	Rule interface {
+		Name() string
+		Check(ctx context.Context, pair Pair) error
+	}
+
+	// Violation explains why a pair breaks a rule.
+	Violation struct {
+		Rule   string
+		Reason string
+	}
+
+	Result struct {
+		Rule   string `json:"rule"`
+		Passed bool   `json:"passed"`
+		Reason string `json:"reason,omitempty"`
+	}
+
+	// Engine runs an ordered set of rules against a pair of cats.
+	Engine struct {
+		rules []Rule
+	}
+)
+
+func (v *Violation) Error() string {
+	return v.Reason
+}
+
+func NewEngine(rules ...Rule) *Engine {
+	return &Engine{rules: rules}
+}
+
+// Validate returns the Violation of the first rule the pair breaks, or nil when
+// every rule passes.
+func (e *Engine) Validate(ctx context.Context, pair Pair) error {
+	for _, rule := range e.rules {
+		if err := rule.Check(ctx, pair); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Check runs every rule, even after one failed, and reports each outcome.
+func (e *Engine) Check(ctx context.Context, pair Pair) ([]Result, error) {
+	results := make([]Result, 0, len(e.rules))
+
+	for _, rule := range e.rules {
+		result := Result{Rule: rule.Name(), Passed: true}
+
+		if err := rule.Check(ctx, pair); err != nil {
+			var violation *Violation
+			if !errors.As(err, &violation) {
+				return nil, err
+			}
+
+			result.Passed = false
+			result.Reason = violation.Reason
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,73 @@
+package match
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestEngineValidate(t *testing.T) {
+	engine := NewEngine(Unmatched(), OppositeSex(), SameRace())
+
+	tests := []struct {
+		name     string
+		pair     Pair
+		wantRule string
+	}{
+		{"every rule passes", newPair(nil), ""},
+		{"first failing rule wins", newPair(func(p *Pair) {
+			p.MatchCat.Sex = "male"
+			p.MatchCat.Race = "Sphynx"
+		}), "opposite_sex"},
+		{"later rule", newPair(func(p *Pair) { p.MatchCat.Race = "Sphynx" }), "same_race"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := engine.Validate(context.Background(), tt.pair)
+
+			var violation *Violation
+			switch {
+			case tt.wantRule == "" && err != nil:
+				t.Errorf("Validate() = %v, want nil", err)
+			case tt.wantRule != "" && !errors.As(err, &violation):
+				t.Errorf("Validate() = %v, want a violation of %s", err, tt.wantRule)
+			case tt.wantRule != "" && violation.Rule != tt.wantRule:
+				t.Errorf("Validate() broke %s, want %s", violation.Rule, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestEngineCheck(t *testing.T) {
+	engine := NewEngine(Unmatched(), OppositeSex(), SameRace())
+
+	results, err := engine.Check(context.Background(), newPair(func(p *Pair) {
+		p.MatchCat.Sex = "male"
+		p.MatchCat.Race = "Sphynx"
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Result{
+		{Rule: "unmatched", Passed: true},
+		{Rule: "opposite_sex", Passed: false, Reason: "both cats have the same sex"},
+		{Rule: "same_race", Passed: false, Reason: "the cats are not of the same race"},
+	}
+	if !reflect.DeepEqual(results, want) {
+		t.Errorf("Check() = %+v, want %+v", results, want)
+	}
+}
+
+func TestEngineCheckStopsOnError(t *testing.T) {
+	failure := errors.New("database down")
+	engine := NewEngine(Unmatched(), ruleFunc{"broken", func(ctx context.Context, pair Pair) error {
+		return failure
+	}})
+
+	if _, err := engine.Check(context.Background(), newPair(nil)); !errors.Is(err, failure) {
+		t.Errorf("Check() = %v, want %v", err, failure)
+	}
+}
@@ -0,0 +1,205 @@
+package match
+
+import (
+	"CatsSocial/configs"
+	"context"
+	"fmt"
+)
+
+type (
+	// PendingCounter counts the pending requests a cat sent.
+	PendingCounter interface {
+		CountPending(ctx context.Context, catId int) (int, error)
+	}
+
+	// BlockList tells whether two users blocked each other, in either direction.
+	BlockList interface {
+		IsBlocked(ctx context.Context, userId, otherUserId int) (bool, error)
+	}
+
+	// ruleFunc adapts a plain function to the Rule interface.
+	ruleFunc struct {
+		name  string
+		check func(ctx context.Context, pair Pair) error
+	}
+
+	// StaticBlockList is a BlockList read from the config.
+	StaticBlockList map[[2]int]bool
+
+	// BlockLists blocks a pair as soon as one of its lists does.
+	BlockLists []BlockList
+)
+
+func (r ruleFunc) Name() string {
+	return r.name
+}
+
+func (r ruleFunc) Check(ctx context.Context, pair Pair) error {
+	return r.check(ctx, pair)
+}
+
+func violation(rule, reason string) error {
+	return &Violation{Rule: rule, Reason: reason}
+}
+
+// New builds the engine configured in config. The unmatched, opposite sex and
+// different owner rules always apply, the others are enabled by their setting.
+// The block lists of the users are checked together with the one of the config.
+func New(config configs.Config, pending PendingCounter, blocks BlockList) *Engine {
+	rules := []Rule{Unmatched(), OppositeSex(), DifferentOwners()}
+
+	if config.MatchSameRaceOnly {
+		rules = append(rules, SameRace())
+	}
+
+	if config.MatchMaxAgeGap > 0 {
+		rules = append(rules, AgeWindow(config.MatchMaxAgeGap))
+	}
+
+	if config.MatchMaxPendingPerCat > 0 {
+		rules = append(rules, MaxPending(config.MatchMaxPendingPerCat, pending))
+	}
+
+	if blockLists := newBlockLists(config, blocks); len(blockLists) > 0 {
+		rules = append(rules, NotBlocked(blockLists))
+	}
+
+	return NewEngine(rules...)
+}
+
+// NewApproval builds the engine run before a request is approved. Only the block
+// lists apply, the owners may have blocked each other since the request was sent.
+func NewApproval(config configs.Config, blocks BlockList) *Engine {
+	rules := []Rule{}
+
+	if blockLists := newBlockLists(config, blocks); len(blockLists) > 0 {
+		rules = append(rules, NotBlocked(blockLists))
+	}
+
+	return NewEngine(rules...)
+}
+
+// newBlockLists joins the block list of the config with the one of the users.
+func newBlockLists(config configs.Config, blocks BlockList) BlockLists {
+	blockLists := BlockLists{}
+	if len(config.MatchBlockList) > 0 {
+		blockLists = append(blockLists, NewStaticBlockList(config.MatchBlockList))
+	}
+	if blocks != nil {
+		blockLists = append(blockLists, blocks)
+	}
+
+	return blockLists
+}
+
+// Unmatched requires both cats to be free.
+func Unmatched() Rule {
+	return ruleFunc{"unmatched", func(ctx context.Context, pair Pair) error {
+		if pair.UserCat.HasMatched || pair.MatchCat.HasMatched {
+			return violation("unmatched", "one of the cats is already matched")
+		}
+		return nil
+	}}
+}
+
+func OppositeSex() Rule {
+	return ruleFunc{"opposite_sex", func(ctx context.Context, pair Pair) error {
+		if pair.UserCat.Sex == pair.MatchCat.Sex {
+			return violation("opposite_sex", "both cats have the same sex")
+		}
+		return nil
+	}}
+}
+
+func DifferentOwners() Rule {
+	return ruleFunc{"different_owners", func(ctx context.Context, pair Pair) error {
+		if pair.UserCat.UserId == pair.MatchCat.UserId {
+			return violation("different_owners", "both cats belong to the same owner")
+		}
+		return nil
+	}}
+}
+
+func SameRace() Rule {
+	return ruleFunc{"same_race", func(ctx context.Context, pair Pair) error {
+		if pair.UserCat.Race != pair.MatchCat.Race {
+			return violation("same_race", "the cats are not of the same race")
+		}
+		return nil
+	}}
+}
+
+// AgeWindow bounds the age difference of the cats, in months.
+func AgeWindow(maxGap int) Rule {
+	return ruleFunc{"age_window", func(ctx context.Context, pair Pair) error {
+		gap := pair.UserCat.AgeInMonth - pair.MatchCat.AgeInMonth
+		if gap < 0 {
+			gap = -gap
+		}
+
+		if gap > maxGap {
+			return violation("age_window", fmt.Sprintf("the cats are %d months apart, at most %d are allowed", gap, maxGap))
+		}
+		return nil
+	}}
+}
+
+// MaxPending caps the pending requests sent from the same cat. Answering a request
+// with a reciprocal one does not open a new request, so it is always allowed.
+func MaxPending(max int, pending PendingCounter) Rule {
+	return ruleFunc{"max_pending", func(ctx context.Context, pair Pair) error {
+		if pair.Reciprocal {
+			return nil
+		}
+
+		count, err := pending.CountPending(ctx, pair.UserCat.Id)
+		if err != nil {
+			return err
+		}
+
+		if count >= max {
+			return violation("max_pending", fmt.Sprintf("the cat already has %d pending requests", count))
+		}
+		return nil
+	}}
+}
+
+// NotBlocked refuses pairs whose owners blocked each other.
+func NotBlocked(blocks BlockList) Rule {
+	return ruleFunc{"not_blocked", func(ctx context.Context, pair Pair) error {
+		blocked, err := blocks.IsBlocked(ctx, pair.UserCat.UserId, pair.MatchCat.UserId)
+		if err != nil {
+			return err
+		}
+
+		if blocked {
+			return violation("not_blocked", "the owners blocked each other")
+		}
+		return nil
+	}}
+}
+
+// NewStaticBlockList indexes the blocked user pairs of the config both ways.
+func NewStaticBlockList(pairs [][2]int) StaticBlockList {
+	blocks := StaticBlockList{}
+	for _, pair := range pairs {
+		blocks[pair] = true
+		blocks[[2]int{pair[1], pair[0]}] = true
+	}
+	return blocks
+}
+
+func (b StaticBlockList) IsBlocked(ctx context.Context, userId, otherUserId int) (bool, error) {
+	return b[[2]int{userId, otherUserId}], nil
+}
+
+func (b BlockLists) IsBlocked(ctx context.Context, userId, otherUserId int) (bool, error) {
+	for _, blocks := range b {
+		blocked, err := blocks.IsBlocked(ctx, userId, otherUserId)
+		if err != nil || blocked {
+			return blocked, err
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,122 @@
+package match
+
+import (
+	"CatsSocial/configs"
+	"CatsSocial/db/models"
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type fakePending map[int]int
+
+func (f fakePending) CountPending(ctx context.Context, catId int) (int, error) {
+	return f[catId], nil
+}
+
+func newPair(modify func(p *Pair)) Pair {
+	pair := Pair{
+		UserCat:  models.Cat{Id: 1, UserId: 10, Sex: "male", Race: "Persian", AgeInMonth: 12},
+		MatchCat: models.Cat{Id: 2, UserId: 20, Sex: "female", Race: "Persian", AgeInMonth: 18},
+	}
+	if modify != nil {
+		modify(&pair)
+	}
+	return pair
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+		pair Pair
+		want bool
+	}{
+		{"unmatched", Unmatched(), newPair(nil), true},
+		{"user cat matched", Unmatched(), newPair(func(p *Pair) { p.UserCat.HasMatched = true }), false},
+		{"match cat matched", Unmatched(), newPair(func(p *Pair) { p.MatchCat.HasMatched = true }), false},
+		{"opposite sex", OppositeSex(), newPair(nil), true},
+		{"same sex", OppositeSex(), newPair(func(p *Pair) { p.MatchCat.Sex = "male" }), false},
+		{"different owners", DifferentOwners(), newPair(nil), true},
+		{"same owner", DifferentOwners(), newPair(func(p *Pair) { p.MatchCat.UserId = 10 }), false},
+		{"same race", SameRace(), newPair(nil), true},
+		{"different race", SameRace(), newPair(func(p *Pair) { p.MatchCat.Race = "Sphynx" }), false},
+		{"age gap within", AgeWindow(6), newPair(nil), true},
+		{"age gap reversed", AgeWindow(6), newPair(func(p *Pair) { p.UserCat.AgeInMonth = 24 }), true},
+		{"age gap too wide", AgeWindow(5), newPair(nil), false},
+		{"pending below max", MaxPending(3, fakePending{1: 2}), newPair(nil), true},
+		{"pending at max", MaxPending(3, fakePending{1: 3}), newPair(nil), false},
+		{"pending at max reciprocal", MaxPending(3, fakePending{1: 3}), newPair(func(p *Pair) { p.Reciprocal = true }), true},
+		{"not blocked", NotBlocked(NewStaticBlockList([][2]int{{10, 30}})), newPair(nil), true},
+		{"blocked", NotBlocked(NewStaticBlockList([][2]int{{10, 20}})), newPair(nil), false},
+		{"blocked the other way", NotBlocked(NewStaticBlockList([][2]int{{20, 10}})), newPair(nil), false},
+		{"blocked by one of the lists", NotBlocked(BlockLists{StaticBlockList{}, NewStaticBlockList([][2]int{{10, 20}})}), newPair(nil), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.Check(context.Background(), tt.pair)
+			if tt.want {
+				if err != nil {
+					t.Errorf("%s.Check() = %v, want nil", tt.rule.Name(), err)
+				}
+				return
+			}
+
+			var violation *Violation
+			if !errors.As(err, &violation) {
+				t.Fatalf("%s.Check() = %v, want a violation", tt.rule.Name(), err)
+			}
+			if violation.Rule != tt.rule.Name() {
+				t.Errorf("violation rule = %s, want %s", violation.Rule, tt.rule.Name())
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name   string
+		config configs.Config
+		want   []string
+	}{
+		{"defaults", configs.Config{}, []string{"unmatched", "opposite_sex", "different_owners"}},
+		{"every rule", configs.Config{
+			MatchSameRaceOnly:     true,
+			MatchMaxAgeGap:        12,
+			MatchMaxPendingPerCat: 5,
+			MatchBlockList:        [][2]int{{1, 2}},
+		}, []string{"unmatched", "opposite_sex", "different_owners", "same_race", "age_window", "max_pending", "not_blocked"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := New(tt.config, fakePending{}, nil)
+
+			names := []string{}
+			for _, rule := range engine.rules {
+				names = append(names, rule.Name())
+			}
+
+			if !reflect.DeepEqual(names, tt.want) {
+				t.Errorf("New() rules = %v, want %v", names, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewApproval(t *testing.T) {
+	pair := newPair(nil)
+
+	if err := NewApproval(configs.Config{}, nil).Validate(context.Background(), pair); err != nil {
+		t.Errorf("Validate() without block lists = %v, want nil", err)
+	}
+
+	engine := NewApproval(configs.Config{MatchBlockList: [][2]int{{20, 10}}}, nil)
+
+	var violation *Violation
+	if err := engine.Validate(context.Background(), pair); !errors.As(err, &violation) || violation.Rule != "not_blocked" {
+		t.Errorf("Validate() of blocked owners = %v, want a not_blocked violation", err)
+	}
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Local stores files on the disk of the API server, they are served by the app itself.
+type Local struct {
+	dir       string
+	publicURL string
+}
+
+func NewLocal(dir, publicURL string) (*Local, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed create storage dir: %v", err)
+	}
+
+	return &Local{
+		dir:       dir,
+		publicURL: strings.TrimSuffix(publicURL, "/"),
+	}, nil
+}
+
+// Dir is the directory the files are written to.
+func (l *Local) Dir() string {
+	return l.dir
+}
+
+func (l *Local) path(key string) (string, error) {
+	path := filepath.Join(l.dir, filepath.FromSlash(key))
+
+	// never write or delete outside of the storage directory
+	rel, err := filepath.Rel(l.dir, path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("invalid storage key %q", key)
+	}
+
+	return path, nil
+}
+
+func (l *Local) Save(ctx context.Context, key, contentType string, content []byte) (string, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("failed create storage dir: %v", err)
+	}
+
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		return "", fmt.Errorf("failed write file: %v", err)
+	}
+
+	return l.publicURL + "/" + key, nil
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed delete file: %v", err)
+	}
+
+	return nil
+}
+
+func (l *Local) Key(url string) (string, bool) {
+	return keyFromURL(l.publicURL, url)
+}
@@ -0,0 +1,197 @@
+package storage
+
+import (
+	"CatsSocial/configs"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// S3 stores files in an S3 compatible bucket (AWS S3, MinIO, ...). Requests are
+// signed with AWS signature version 4.
+type S3 struct {
+	endpoint     *url.URL
+	region       string
+	bucket       string
+	accessKey    string
+	secretKey    string
+	usePathStyle bool
+	publicURL    string
+	client       *http.Client
+}
+
+func NewS3(config configs.Config) (*S3, error) {
+	if config.S3Endpoint == "" || config.S3Bucket == "" {
+		return nil, errors.New("S3_ENDPOINT and S3_BUCKET are required for the s3 storage")
+	}
+
+	endpoint, err := url.Parse(strings.TrimSuffix(config.S3Endpoint, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("failed parse S3_ENDPOINT: %v", err)
+	}
+
+	s := &S3{
+		endpoint:     endpoint,
+		region:       config.S3Region,
+		bucket:       config.S3Bucket,
+		accessKey:    config.S3AccessKeyId,
+		secretKey:    config.S3SecretAccessKey,
+		usePathStyle: config.S3UsePathStyle,
+		publicURL:    strings.TrimSuffix(config.StoragePublicURL, "/"),
+		client:       &http.Client{Timeout: 30 * time.Second},
+	}
+
+	// without a CDN in front, objects are served straight from the bucket
+	if s.publicURL == "" {
+		s.publicURL = s.objectURL("").String()
+		s.publicURL = strings.TrimSuffix(s.publicURL, "/")
+	}
+
+	return s, nil
+}
+
+func (s *S3) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	if s.usePathStyle {
+		u.Path = "/" + s.bucket + "/" + key
+	} else {
+		u.Host = s.bucket + "." + u.Host
+		u.Path = "/" + key
+	}
+
+	return &u
+}
+
+func (s *S3) Save(ctx context.Context, key, contentType string, content []byte) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(key).String(), bytes.NewReader(content))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", contentType)
+	s.sign(req, content, time.Now())
+
+	if err := s.do(req); err != nil {
+		return "", fmt.Errorf("failed upload object: %v", err)
+	}
+
+	return s.publicURL + "/" + key, nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(key).String(), nil)
+	if err != nil {
+		return err
+	}
+
+	s.sign(req, nil, time.Now())
+
+	// S3 answers 204 for missing objects too
+	if err := s.do(req); err != nil {
+		return fmt.Errorf("failed delete object: %v", err)
+	}
+
+	return nil
+}
+
+func (s *S3) Key(url string) (string, bool) {
+	return keyFromURL(s.publicURL, url)
+}
+
+func (s *S3) do(req *http.Request) error {
+	res, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("s3 responded %d: %s", res.StatusCode, body)
+	}
+
+	return nil
+}
+
+// sign adds the AWS signature version 4 headers to req.
+func (s *S3) sign(req *http.Request, payload []byte, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	payloadHash := sha256Hex(payload)
+
+	req.Header.Set("Host", req.URL.Host)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if req.Header.Get("Content-Type") != "" {
+		signedHeaders = append([]string{"content-type"}, signedHeaders...)
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, h := range signedHeaders {
+		value := req.Header.Get(h)
+		if h == "host" {
+			value = req.URL.Host
+		}
+		canonicalHeaders.WriteString(h + ":" + strings.TrimSpace(value) + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := signingKey(s.secretKey, date, s.region, "s3")
+
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKey, scope, strings.Join(signedHeaders, ";"), signature,
+	))
+}
+
+// signingKey derives the signature version 4 key of a secret for one day, region
+// and service.
+func signingKey(secret, date, region, service string) []byte {
+	key := hmacSHA256([]byte("AWS4"+secret), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	return hmacSHA256(key, "aws4_request")
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
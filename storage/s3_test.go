@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"CatsSocial/configs"
+	"context"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSigningKey(t *testing.T) {
+	// example from the AWS signature version 4 documentation
+	key := signingKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+
+	want := "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d"
+	if got := hex.EncodeToString(key); got != want {
+		t.Errorf("signingKey() = %s, want %s", got, want)
+	}
+}
+
+// expectedAuthorization signs the request the way S3 checks it on arrival.
+func expectedAuthorization(r *http.Request, body []byte, accessKey, secretKey, region string) string {
+	amzDate := r.Header.Get("X-Amz-Date")
+	date := amzDate[:8]
+
+	signedHeaders := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	if r.Header.Get("Content-Type") != "" {
+		signedHeaders = append([]string{"content-type"}, signedHeaders...)
+	}
+
+	var headers strings.Builder
+	for _, h := range signedHeaders {
+		value := r.Header.Get(h)
+		if h == "host" {
+			value = r.Host
+		}
+		headers.WriteString(h + ":" + value + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		r.URL.RawQuery,
+		headers.String(),
+		strings.Join(signedHeaders, ";"),
+		sha256Hex(body),
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+	signature := hex.EncodeToString(hmacSHA256(signingKey(secretKey, date, region, "s3"), stringToSign))
+
+	return "AWS4-HMAC-SHA256 Credential=" + accessKey + "/" + scope +
+		", SignedHeaders=" + strings.Join(signedHeaders, ";") + ", Signature=" + signature
+}
+
+func TestS3SignsRequests(t *testing.T) {
+	const (
+		accessKey = "AKIDEXAMPLE"
+		secretKey = "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"
+		region    = "us-east-1"
+	)
+
+	tests := []struct {
+		name   string
+		method string
+		call   func(s *S3) error
+		body   string
+	}{
+		{
+			name:   "put",
+			method: http.MethodPut,
+			call: func(s *S3) error {
+				_, err := s.Save(context.Background(), "cats/1.jpg", "image/jpeg", []byte("meow"))
+				return err
+			},
+			body: "meow",
+		},
+		{
+			name:   "delete",
+			method: http.MethodDelete,
+			call: func(s *S3) error {
+				return s.Delete(context.Background(), "cats/1.jpg")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+
+				if r.Method != tt.method {
+					t.Errorf("method = %s, want %s", r.Method, tt.method)
+				}
+				if r.URL.Path != "/bucket/cats/1.jpg" {
+					t.Errorf("path = %s, want /bucket/cats/1.jpg", r.URL.Path)
+				}
+				if string(body) != tt.body {
+					t.Errorf("body = %q, want %q", body, tt.body)
+				}
+
+				if got, want := r.Header.Get("X-Amz-Content-Sha256"), sha256Hex([]byte(tt.body)); got != want {
+					t.Errorf("x-amz-content-sha256 = %s, want %s", got, want)
+				}
+
+				amzDate, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date"))
+				if err != nil {
+					t.Fatalf("x-amz-date %q: %v", r.Header.Get("X-Amz-Date"), err)
+				}
+				if since := time.Since(amzDate); since < -time.Minute || since > time.Minute {
+					t.Errorf("x-amz-date = %s, want the current time", amzDate)
+				}
+
+				if got, want := r.Header.Get("Authorization"), expectedAuthorization(r, body, accessKey, secretKey, region); got != want {
+					t.Errorf("authorization = %s, want %s", got, want)
+				}
+
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			s, err := NewS3(configs.Config{
+				S3Endpoint:        server.URL,
+				S3Region:          region,
+				S3Bucket:          "bucket",
+				S3AccessKeyId:     accessKey,
+				S3SecretAccessKey: secretKey,
+				S3UsePathStyle:    true,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := tt.call(s); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package storage
+
+import (
+	"CatsSocial/configs"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// Storage keeps uploaded files and hands out the public URL they are served from.
+type Storage interface {
+	// Save stores content under key and returns its public URL.
+	Save(ctx context.Context, key, contentType string, content []byte) (string, error)
+	// Delete removes the file stored under key, deleting a missing file is not an error.
+	Delete(ctx context.Context, key string) error
+	// Key returns the key of a URL served by this storage, ok is false for foreign URLs.
+	Key(url string) (key string, ok bool)
+}
+
+func New(config configs.Config) (Storage, error) {
+	switch config.StorageDriver {
+	case "local":
+		publicURL := config.StoragePublicURL
+		if publicURL == "" {
+			publicURL = "http://localhost:" + config.APPPort + "/uploads"
+		}
+		return NewLocal(config.StorageLocalDir, publicURL)
+	case "s3":
+		return NewS3(config)
+	default:
+		return nil, fmt.Errorf("unsupported storage driver %q", config.StorageDriver)
+	}
+}
+
+// RemoveUnused deletes the files behind urls that isUsed reports as no longer
+// referenced. Failing to do so is only logged, the file is leaked.
+func RemoveUnused(ctx context.Context, store Storage, isUsed func(ctx context.Context, url string) (bool, error), urls []string) {
+	for _, url := range urls {
+		key, ok := store.Key(url)
+		if !ok {
+			continue
+		}
+
+		used, err := isUsed(ctx, url)
+		if err != nil || used {
+			continue
+		}
+
+		if err := store.Delete(ctx, key); err != nil {
+			log.Printf("failed remove image %s: %v", key, err)
+		}
+	}
+}
+
+// keyFromURL strips the base URL of a storage from url.
+func keyFromURL(baseURL, url string) (string, bool) {
+	key, found := strings.CutPrefix(url, strings.TrimSuffix(baseURL, "/")+"/")
+	if !found || key == "" {
+		return "", false
+	}
+
+	return key, true
+}
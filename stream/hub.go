@@ -0,0 +1,73 @@
+package stream
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// subscriberBuffer is how many events a slow subscriber can lag behind before
+// new events are dropped for it.
+const subscriberBuffer = 16
+
+type (
+	// Event is pushed to every open stream of a user, Data is already JSON encoded.
+	Event struct {
+		Id     int
+		UserId int
+		Type   string
+		Data   json.RawMessage
+	}
+
+	// Hub fans events out to the streams open in this process.
+	Hub struct {
+		mu          sync.RWMutex
+		subscribers map[int]map[chan Event]struct{}
+	}
+)
+
+func NewHub() *Hub {
+	return &Hub{
+		subscribers: map[int]map[chan Event]struct{}{},
+	}
+}
+
+// Subscribe opens a stream for a user, the returned function closes it.
+func (h *Hub) Subscribe(userId int) (<-chan Event, func()) {
+	events := make(chan Event, subscriberBuffer)
+
+	h.mu.Lock()
+	if h.subscribers[userId] == nil {
+		h.subscribers[userId] = map[chan Event]struct{}{}
+	}
+	h.subscribers[userId][events] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subscribers[userId], events)
+			if len(h.subscribers[userId]) == 0 {
+				delete(h.subscribers, userId)
+			}
+			h.mu.Unlock()
+			close(events)
+		})
+	}
+
+	return events, unsubscribe
+}
+
+// Publish sends an event to the open streams of its user without blocking.
+func (h *Hub) Publish(event Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for events := range h.subscribers[event.UserId] {
+		select {
+		case events <- event:
+		default:
+			// the client stopped reading, it would rather miss an event than stall the others
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// NotificationChannel is the postgres channel a trigger notifies on every new
+// notification, so that every server instance can push it to its streams.
+const NotificationChannel = "notifications"
+
+// Listen feeds the hub with the notifications postgres announces until ctx is done,
+// reconnecting whenever the connection drops.
+func Listen(ctx context.Context, dbPool *pgxpool.Pool, hub *Hub) {
+	backoff := time.Second
+
+	for {
+		err := listen(ctx, dbPool, hub)
+		if ctx.Err() != nil {
+			return
+		}
+
+		log.Printf("notification listener stopped, retrying in %s: %v", backoff, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		if backoff < time.Minute {
+			backoff *= 2
+		}
+	}
+}
+
+func listen(ctx context.Context, dbPool *pgxpool.Pool, hub *Hub) error {
+	conn, err := dbPool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Release()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+NotificationChannel); err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+
+		var payload struct {
+			Id     int    `json:"id"`
+			UserId int    `json:"userId"`
+			Type   string `json:"type"`
+		}
+
+		if err := json.Unmarshal([]byte(notification.Payload), &payload); err != nil {
+			log.Printf("failed decode notification payload: %v", err)
+			continue
+		}
+
+		hub.Publish(Event{
+			Id:     payload.Id,
+			UserId: payload.UserId,
+			Type:   payload.Type,
+			Data:   json.RawMessage(notification.Payload),
+		})
+	}
+}
@@ -0,0 +1,178 @@
+// Package webhook sends the deliveries of the webhooks users register, signed so
+// that receivers can tell they come from this server.
+package webhook
+
+import (
+	"CatsSocial/db/models"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Headers sent with every delivery. The signature is the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the webhook secret, prefixed with "sha256=".
+const (
+	HeaderEvent     = "X-CatsSocial-Event"
+	HeaderDelivery  = "X-CatsSocial-Delivery"
+	HeaderTimestamp = "X-CatsSocial-Timestamp"
+	HeaderSignature = "X-CatsSocial-Signature"
+)
+
+// ErrPrivateAddress is returned for webhook urls pointing into the network of the
+// server, e.g. loopback, private ranges or the cloud metadata endpoint.
+var ErrPrivateAddress = errors.New("webhook url must not point to a private address")
+
+// maxBackoff caps the wait between two attempts of a delivery.
+const maxBackoff = 6 * time.Hour
+
+// Sign returns the signature header value of a body sent at timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Backoff is how long to wait before the next attempt once attempts have failed:
+// base, then twice as long after every further failure.
+func Backoff(base time.Duration, attempts int) time.Duration {
+	wait := base
+	for i := 1; i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+
+	if wait > maxBackoff {
+		return maxBackoff
+	}
+
+	return wait
+}
+
+// isPrivate reports whether ip is in a range a webhook must not reach: loopback,
+// RFC 1918 and unique local, link local (169.254.169.254 among them), multicast
+// and unspecified addresses.
+func isPrivate(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified()
+}
+
+// CheckURL resolves the host of a webhook url and refuses it when any of its
+// addresses is private, unless allowPrivate is set.
+func CheckURL(ctx context.Context, rawURL string, allowPrivate bool) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return errors.New("webhook url must be http or https")
+	}
+
+	if allowPrivate {
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("failed resolve webhook host: %v", err)
+	}
+
+	for _, addr := range addrs {
+		if isPrivate(addr.IP) {
+			return ErrPrivateAddress
+		}
+	}
+
+	return nil
+}
+
+// refusePrivate is a dialer control refusing connections to private addresses. It
+// checks the address actually dialed, so a host resolving to a public address at
+// registration and to a private one later is still refused.
+func refusePrivate(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || isPrivate(ip) {
+		return ErrPrivateAddress
+	}
+
+	return nil
+}
+
+type Sender struct {
+	client *http.Client
+}
+
+// NewSender builds a Sender giving receivers timeout to answer. Private addresses
+// are refused unless allowPrivate is set, which is meant for tests and local setups.
+func NewSender(timeout time.Duration, allowPrivate bool) *Sender {
+	dialer := &net.Dialer{Timeout: timeout}
+	if !allowPrivate {
+		dialer.Control = refusePrivate
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	// a proxy would make the dialed address the one of the proxy
+	transport.Proxy = nil
+
+	return &Sender{
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: transport,
+			// a redirect would resend the payload somewhere the user did not register
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Send posts a delivery to its webhook. It returns the response status code, or 0
+// when no response came back, and an error unless the receiver answered 2xx.
+func (s *Sender) Send(ctx context.Context, delivery models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed build request: %v", err)
+	}
+
+	timestamp := time.Now().Unix()
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, strconv.Itoa(delivery.Id))
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	defer resp.Body.Close()
+
+	// drain a little of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
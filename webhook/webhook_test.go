@@ -0,0 +1,102 @@
+package webhook
+
+import (
+	"CatsSocial/db/models"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"event":"match_approved"}`)
+
+	tests := []struct {
+		name      string
+		secret    string
+		timestamp int64
+		want      string
+	}{
+		{"known answer", "secret", 1700000000, "sha256=9ee403e1babed5fd6e25a54dcd181ff77dff70a5cc32340b19006a97ae472c99"},
+		{"other secret", "other", 1700000000, "sha256=b95ca4280f5d72d325efc645dbf481a91a84e5cf3fe7c2cd45815693396e4b30"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sign(tt.secret, tt.timestamp, body); got != tt.want {
+				t.Errorf("Sign() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	if Sign("secret", 1700000001, body) == Sign("secret", 1700000000, body) {
+		t.Error("Sign() does not cover the timestamp")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{6, 16 * time.Minute},
+		{10, 256 * time.Minute},
+		{11, maxBackoff},
+		{100, maxBackoff},
+	}
+
+	for _, tt := range tests {
+		if got := Backoff(30*time.Second, tt.attempts); got != tt.want {
+			t.Errorf("Backoff(30s, %d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		name         string
+		url          string
+		allowPrivate bool
+		wantErr      bool
+	}{
+		{"public", "https://93.184.216.34/hooks", false, false},
+		{"loopback", "http://127.0.0.1:8080/hooks", false, true},
+		{"loopback ipv6", "http://[::1]/hooks", false, true},
+		{"rfc 1918", "http://10.1.2.3/hooks", false, true},
+		{"rfc 1918 192.168", "http://192.168.0.10/hooks", false, true},
+		{"metadata endpoint", "http://169.254.169.254/latest/meta-data", false, true},
+		{"unspecified", "http://0.0.0.0/hooks", false, true},
+		{"loopback allowed", "http://127.0.0.1:8080/hooks", true, false},
+		{"not http", "ftp://93.184.216.34/hooks", false, true},
+		{"not http even when allowed", "file:///etc/passwd", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckURL(context.Background(), tt.url, tt.allowPrivate)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CheckURL(%q) = %v, want error %v", tt.url, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSenderRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the request reached a loopback receiver")
+	}))
+	defer server.Close()
+
+	delivery := models.WebhookDelivery{Id: 1, Event: "match_approved", Payload: []byte(`{}`), Url: server.URL, Secret: "secret"}
+
+	_, err := NewSender(time.Second, false).Send(context.Background(), delivery)
+	if !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("Send() = %v, want ErrPrivateAddress", err)
+	}
+}
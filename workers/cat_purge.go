@@ -0,0 +1,31 @@
+package workers
+
+import (
+	"CatsSocial/db/functions"
+	"CatsSocial/storage"
+	"context"
+	"log"
+	"time"
+)
+
+// PurgeDeletedCats removes cats deleted more than olderThan ago, along with the
+// images no other cat uses, every interval until ctx is done.
+func PurgeDeletedCats(ctx context.Context, cats *functions.Cat, store storage.Storage, olderThan, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		imageUrls, err := cats.PurgeDeleted(ctx, olderThan)
+		if err != nil {
+			log.Printf("failed purge deleted cats: %v", err)
+		} else {
+			storage.RemoveUnused(ctx, store, cats.IsImageUsed, imageUrls)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
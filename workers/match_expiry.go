@@ -0,0 +1,30 @@
+package workers
+
+import (
+	"CatsSocial/db/functions"
+	"context"
+	"log"
+	"time"
+)
+
+// ExpireMatches moves pending match requests past their TTL to expired every
+// interval until ctx is done.
+func ExpireMatches(ctx context.Context, matches *functions.Match, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		expired, err := matches.ExpirePending(ctx)
+		if err != nil {
+			log.Printf("failed expire match requests: %v", err)
+		} else if expired > 0 {
+			log.Printf("expired %d match requests", expired)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package workers
+
+import (
+	"CatsSocial/db/models"
+	"CatsSocial/webhook"
+	"context"
+	"log"
+	"time"
+)
+
+// webhookBatchSize is how many deliveries are claimed at once.
+const webhookBatchSize = 50
+
+// WebhookStore is the outbox the delivery worker works through, implemented by
+// functions.Webhook.
+type WebhookStore interface {
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error)
+	MarkDelivered(ctx context.Context, id, statusCode int) error
+	MarkAttemptFailed(ctx context.Context, id int, statusCode *int, reason string, retryIn *time.Duration) error
+}
+
+// DeliverWebhooks sends the due webhook deliveries every interval until ctx is
+// done. Failed attempts are retried with an exponential backoff starting at
+// retryBase, a delivery is given up on after maxAttempts attempts.
+func DeliverWebhooks(ctx context.Context, webhooks WebhookStore, sender *webhook.Sender, interval, timeout, retryBase time.Duration, maxAttempts int) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	// deliveries are sent one after another, the lease has to outlast a full batch
+	lease := timeout*webhookBatchSize + interval
+
+	for {
+		deliverDue(ctx, webhooks, sender, lease, retryBase, maxAttempts)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// deliverDue sends one batch of due deliveries and records how each attempt went.
+func deliverDue(ctx context.Context, webhooks WebhookStore, sender *webhook.Sender, lease, retryBase time.Duration, maxAttempts int) {
+	deliveries, err := webhooks.ClaimDue(ctx, webhookBatchSize, lease)
+	if err != nil {
+		log.Printf("failed claim webhook deliveries: %v", err)
+	}
+
+	for _, delivery := range deliveries {
+		statusCode, err := sender.Send(ctx, delivery)
+		if err == nil {
+			if err := webhooks.MarkDelivered(ctx, delivery.Id, statusCode); err != nil {
+				log.Printf("failed record webhook delivery %d: %v", delivery.Id, err)
+			}
+			continue
+		}
+
+		var code *int
+		if statusCode != 0 {
+			code = &statusCode
+		}
+
+		var retryIn *time.Duration
+		if attempts := delivery.Attempts + 1; attempts < maxAttempts {
+			wait := webhook.Backoff(retryBase, attempts)
+			retryIn = &wait
+		}
+
+		if err := webhooks.MarkAttemptFailed(ctx, delivery.Id, code, err.Error(), retryIn); err != nil {
+			log.Printf("failed record webhook delivery %d: %v", delivery.Id, err)
+		}
+	}
+}
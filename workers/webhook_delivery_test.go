@@ -0,0 +1,167 @@
+package workers
+
+import (
+	"CatsSocial/db/models"
+	"CatsSocial/webhook"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type attempt struct {
+	id         int
+	delivered  bool
+	statusCode *int
+	retryIn    *time.Duration
+}
+
+// fakeWebhookStore hands out its deliveries once they are due, like the outbox table.
+type fakeWebhookStore struct {
+	mu         sync.Mutex
+	deliveries map[int]*models.WebhookDelivery
+	attempts   []attempt
+}
+
+func (f *fakeWebhookStore) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	due := []models.WebhookDelivery{}
+	for _, delivery := range f.deliveries {
+		if delivery.Status == models.WebhookDeliveryPending && !delivery.NextAttemptAt.After(time.Now()) {
+			due = append(due, *delivery)
+			delivery.NextAttemptAt = time.Now().Add(lease)
+		}
+	}
+
+	return due, nil
+}
+
+func (f *fakeWebhookStore) MarkDelivered(ctx context.Context, id, statusCode int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delivery := f.deliveries[id]
+	delivery.Status = models.WebhookDeliveryDelivered
+	delivery.Attempts++
+	f.attempts = append(f.attempts, attempt{id: id, delivered: true, statusCode: &statusCode})
+
+	return nil
+}
+
+func (f *fakeWebhookStore) MarkAttemptFailed(ctx context.Context, id int, statusCode *int, reason string, retryIn *time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delivery := f.deliveries[id]
+	delivery.Attempts++
+	delivery.Status = models.WebhookDeliveryFailed
+	if retryIn != nil {
+		delivery.Status = models.WebhookDeliveryPending
+		// the test does not wait for the backoff
+		delivery.NextAttemptAt = time.Now()
+	}
+	f.attempts = append(f.attempts, attempt{id: id, statusCode: statusCode, retryIn: retryIn})
+
+	return nil
+}
+
+func TestDeliverDueRetriesUntilDelivered(t *testing.T) {
+	const secret = "secret"
+	payload := []byte(`{"event":"match_approved","matchId":1}`)
+
+	var calls int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != string(payload) {
+			t.Errorf("body = %s, want %s", body, payload)
+		}
+
+		timestamp, err := strconv.ParseInt(r.Header.Get(webhook.HeaderTimestamp), 10, 64)
+		if err != nil {
+			t.Errorf("timestamp header: %v", err)
+		}
+		if got, want := r.Header.Get(webhook.HeaderSignature), webhook.Sign(secret, timestamp, body); got != want {
+			t.Errorf("signature = %s, want %s", got, want)
+		}
+		if got := r.Header.Get(webhook.HeaderEvent); got != "match_approved" {
+			t.Errorf("event header = %s, want match_approved", got)
+		}
+		if got := r.Header.Get(webhook.HeaderDelivery); got != "7" {
+			t.Errorf("delivery header = %s, want 7", got)
+		}
+
+		// the receiver is down on the first attempt
+		if calls == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	store := &fakeWebhookStore{deliveries: map[int]*models.WebhookDelivery{
+		7: {
+			Id:            7,
+			Event:         "match_approved",
+			Payload:       payload,
+			Status:        models.WebhookDeliveryPending,
+			NextAttemptAt: time.Now(),
+			Url:           server.URL,
+			Secret:        secret,
+		},
+	}}
+
+	sender := webhook.NewSender(time.Second, true)
+
+	deliverDue(context.Background(), store, sender, time.Minute, 30*time.Second, 5)
+	deliverDue(context.Background(), store, sender, time.Minute, 30*time.Second, 5)
+
+	if len(store.attempts) != 2 {
+		t.Fatalf("recorded %d attempts, want 2", len(store.attempts))
+	}
+
+	failed := store.attempts[0]
+	if failed.delivered || failed.statusCode == nil || *failed.statusCode != http.StatusInternalServerError {
+		t.Errorf("first attempt = %+v, want a failure with status 500", failed)
+	}
+	if failed.retryIn == nil || *failed.retryIn != 30*time.Second {
+		t.Errorf("first attempt retries in %v, want 30s", failed.retryIn)
+	}
+
+	delivered := store.attempts[1]
+	if !delivered.delivered || *delivered.statusCode != http.StatusNoContent {
+		t.Errorf("second attempt = %+v, want delivered with status 204", delivered)
+	}
+
+	if got := store.deliveries[7]; got.Status != models.WebhookDeliveryDelivered || got.Attempts != 2 {
+		t.Errorf("delivery = %s after %d attempts, want delivered after 2", got.Status, got.Attempts)
+	}
+}
+
+func TestDeliverDueGivesUp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	store := &fakeWebhookStore{deliveries: map[int]*models.WebhookDelivery{
+		1: {Id: 1, Event: "cat_created", Payload: []byte(`{}`), Status: models.WebhookDeliveryPending, Attempts: 2, NextAttemptAt: time.Now(), Url: server.URL},
+	}}
+
+	deliverDue(context.Background(), store, webhook.NewSender(time.Second, true), time.Minute, time.Second, 3)
+
+	if got := store.deliveries[1]; got.Status != models.WebhookDeliveryFailed {
+		t.Errorf("delivery status = %s after its last attempt, want failed", got.Status)
+	}
+	if store.attempts[0].retryIn != nil {
+		t.Errorf("last attempt retries in %v, want no retry", *store.attempts[0].retryIn)
+	}
+}